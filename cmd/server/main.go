@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/diegotremper/go-animals/infrastructure"
 	"github.com/joho/godotenv"
 )
@@ -10,7 +12,12 @@ func main() {
 
 	logger := infrastructure.InitLogger()
 	db := infrastructure.InitDB()
-	r := infrastructure.SetupRouter(logger, db)
+	keys := infrastructure.InitIdempotencyStore(db)
+	infrastructure.StartIdempotencyCleanup(context.Background(), keys, logger)
+	blobs := infrastructure.InitBlobStore()
+	infrastructure.StartBlobSweeper(context.Background(), db, blobs, logger)
+
+	r := infrastructure.SetupRouter(logger, db, keys, blobs)
 
 	r.Run()
 }
@@ -0,0 +1,31 @@
+package infrastructure
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth only lets requests through that carry the ADMIN_TOKEN environment
+// variable as a bearer token. Without ADMIN_TOKEN every admin request is refused.
+func AdminAuth() gin.HandlerFunc {
+	token := os.Getenv("ADMIN_TOKEN")
+
+	return func(ctx *gin.Context) {
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			problem.Write(ctx, problem.New(problem.TypeUnauthorized, http.StatusUnauthorized, "admin endpoints need a bearer token"))
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			problem.Write(ctx, problem.New(problem.TypeForbidden, http.StatusForbidden, "the token does not grant admin access"))
+			return
+		}
+		ctx.Next()
+	}
+}
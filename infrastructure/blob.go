@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/diegotremper/go-animals/internal/animal"
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultBlobDir    = "data/blobs"
+	blobSweepInterval = 5 * time.Minute
+)
+
+// InitBlobStore returns the store for attachment content. BLOB_STORE selects it:
+// "local" (the default) keeps files below BLOB_DIR, "s3" keeps them in the
+// S3_BUCKET of an S3 compatible service at S3_ENDPOINT.
+func InitBlobStore() blob.Store {
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = defaultBlobDir
+		}
+		return blob.NewFileStore(dir)
+	case "s3":
+		cfg := blob.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		if cfg.Endpoint == "" || cfg.Region == "" || cfg.Bucket == "" {
+			log.Fatalf("BLOB_STORE=s3 needs S3_ENDPOINT, S3_REGION and S3_BUCKET")
+		}
+		store, err := blob.NewS3Store(cfg)
+		if err != nil {
+			log.Fatalf("Invalid S3 configuration: %v", err)
+		}
+		return store
+	default:
+		log.Fatalf("Invalid BLOB_STORE %q: must be local or s3", backend)
+		return nil
+	}
+}
+
+// StartBlobSweeper deletes the blobs of deleted attachments in the background
+// until ctx is done.
+func StartBlobSweeper(ctx context.Context, db *sqlx.DB, blobs blob.Store, logger *slog.Logger) {
+	repo := animal.NewPostgresAttachmentRepository(db)
+
+	go func() {
+		ticker := time.NewTicker(blobSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := animal.SweepAttachmentBlobs(repo, blobs, logger)
+				if err != nil {
+					logger.Error("failed to delete attachment blobs", "error", err)
+				}
+				if deleted > 0 {
+					logger.Info("deleted attachment blobs", "count", deleted)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,36 @@
+package infrastructure
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/diegotremper/go-animals/internal/idempotency"
+	"github.com/jmoiron/sqlx"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// InitIdempotencyStore returns the store of Idempotency-Key responses. Keys live
+// for IDEMPOTENCY_TTL (a Go duration such as "12h"), 24 hours by default.
+func InitIdempotencyStore(db *sqlx.DB) *idempotency.PostgresStore {
+	ttl := defaultIdempotencyTTL
+	if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_TTL %q: must be a positive duration", v)
+		}
+		ttl = parsed
+	}
+
+	return idempotency.NewPostgresStore(db, ttl)
+}
+
+// StartIdempotencyCleanup deletes expired idempotency keys in the background
+// until ctx is done. It runs twice per TTL, but at least once an hour.
+func StartIdempotencyCleanup(ctx context.Context, store *idempotency.PostgresStore, logger *slog.Logger) {
+	interval := min(store.TTL()/2, time.Hour)
+	go idempotency.RunCleanup(ctx, store, max(interval, time.Minute), logger)
+}
@@ -4,6 +4,9 @@ import (
 	"log/slog"
 
 	"github.com/diegotremper/go-animals/internal/animal"
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/diegotremper/go-animals/internal/idempotency"
+	"github.com/diegotremper/go-animals/internal/problem"
 	sloggin "github.com/samber/slog-gin"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +17,9 @@ type AnimalModule struct {
 	logger *slog.Logger
 	db     *sqlx.DB
 	rg     *gin.RouterGroup
+	admin  *gin.RouterGroup
+	keys   idempotency.Store
+	blobs  blob.Store
 }
 
 func (m *AnimalModule) RootLogger() *slog.Logger {
@@ -32,23 +38,43 @@ func (m *AnimalModule) RouterGroup() *gin.RouterGroup {
 	return m.rg
 }
 
-func SetupRouter(logger *slog.Logger, db *sqlx.DB) *gin.Engine {
+func (m *AnimalModule) AdminRouterGroup() *gin.RouterGroup {
+	return m.admin
+}
+
+func (m *AnimalModule) IdempotencyStore() idempotency.Store {
+	return m.keys
+}
+
+func (m *AnimalModule) BlobStore() blob.Store {
+	return m.blobs
+}
+
+func SetupRouter(logger *slog.Logger, db *sqlx.DB, keys idempotency.Store, blobs blob.Store) *gin.Engine {
 	r := gin.Default()
 
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(problem.NoRoute)
+	r.NoMethod(problem.NoMethod)
+
 	r.Use(sloggin.New(logger))
-	r.Use(gin.Recovery())
+	r.Use(gin.CustomRecovery(problem.Recovery))
 
 	r.GET("/ping", func(ctx *gin.Context) {
 		ctx.JSON(200, gin.H{"message": "pong"})
 	})
 
 	root := r.Group("/")
+	admin := r.Group("/admin", AdminAuth())
 
 	// add module routes
 	animal.AddRoutes(&AnimalModule{
 		logger: logger,
 		db:     db,
 		rg:     root,
+		admin:  admin,
+		keys:   keys,
+		blobs:  blobs,
 	})
 
 	return r
@@ -0,0 +1,69 @@
+package infrastructure_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diegotremper/go-animals/infrastructure"
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupRouter_Problems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := infrastructure.SetupRouter(infrastructure.InitLogger(), nil, nil, nil)
+	r.GET("/panic", func(ctx *gin.Context) { panic("boom") })
+
+	cases := []struct {
+		method, path string
+		status       int
+		problemType  string
+	}{
+		{"GET", "/unknown", http.StatusNotFound, problem.TypeNotFound},
+		{"PATCH", "/ping", http.StatusMethodNotAllowed, problem.TypeMethodNotAllowed},
+		{"GET", "/panic", http.StatusInternalServerError, problem.TypeInternal},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-Request-Id", "req-1")
+
+		r.ServeHTTP(w, req)
+
+		var p problem.Problem
+		assert.Equal(t, tc.status, w.Code, tc.path)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), tc.path)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), tc.path)
+		assert.Equal(t, tc.problemType, p.Type, tc.path)
+		assert.Equal(t, tc.status, p.Status, tc.path)
+		assert.Equal(t, tc.path, p.Instance, tc.path)
+		assert.Equal(t, "req-1", p.RequestID, tc.path)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	r := gin.New()
+	r.GET("/admin/ping", infrastructure.AdminAuth(), func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	for authorization, status := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Basic s3cret":  http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusForbidden,
+		"Bearer s3cret": http.StatusNoContent,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/ping", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code, authorization)
+	}
+}
@@ -0,0 +1,130 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// adoptionColumns is the select list that matches the Adoption struct.
+const adoptionColumns = `id, animal_id, owner_id, adopted_on, returned_on, fee_cents, notes, created_at, updated_at`
+
+// AdoptionRepository keeps the adoption records of animals. Adoptions of a soft
+// deleted animal are hidden, and purging the animal removes them.
+type AdoptionRepository interface {
+	// CreateAdoption and UpdateAdoption fail with ErrAlreadyAdopted when the
+	// period overlaps another adoption of the animal, and with ErrUnknownOwner
+	// when the owner does not exist.
+	CreateAdoption(animalID int64, r AdoptionRequest) (Adoption, error)
+	UpdateAdoption(animalID, id int64, r AdoptionRequest) (Adoption, error)
+	// ListAdoptions returns the adoptions of an animal, oldest first.
+	ListAdoptions(animalID int64) ([]Adoption, error)
+	GetAdoption(animalID, id int64) (Adoption, error)
+	DeleteAdoption(animalID, id int64) error
+}
+
+type PostgresAdoptionRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresAdoptionRepository(db *sqlx.DB) *PostgresAdoptionRepository {
+	return &PostgresAdoptionRepository{db: db}
+}
+
+// liveAdoption restricts a query on adoptions aliased d to animals that are not deleted.
+const liveAdoption = `EXISTS (SELECT 1 FROM animals a WHERE a.id = d.animal_id AND a.deleted_at IS NULL)`
+
+func (r *PostgresAdoptionRepository) CreateAdoption(animalID int64, req AdoptionRequest) (Adoption, error) {
+	var adoption Adoption
+	err := r.db.QueryRowx(`INSERT INTO adoptions (animal_id, owner_id, adopted_on, returned_on, fee_cents, notes)
+		SELECT id, $2, $3::date, $4::date, $5, $6 FROM animals WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+adoptionColumns,
+		animalID, req.OwnerID, req.AdoptedOn, req.ReturnedOn, req.FeeCents, req.Notes).StructScan(&adoption)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Adoption{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, animalID)
+		}
+		return Adoption{}, adoptionError(err, animalID, "failed to insert adoption")
+	}
+	return adoption, nil
+}
+
+func (r *PostgresAdoptionRepository) UpdateAdoption(animalID, id int64, req AdoptionRequest) (Adoption, error) {
+	var adoption Adoption
+	err := r.db.QueryRowx(`UPDATE adoptions d
+		SET owner_id = $3, adopted_on = $4, returned_on = $5, fee_cents = $6, notes = $7, updated_at = now()
+		WHERE d.id = $1 AND d.animal_id = $2 AND `+liveAdoption+`
+		RETURNING `+adoptionColumns,
+		id, animalID, req.OwnerID, req.AdoptedOn, req.ReturnedOn, req.FeeCents, req.Notes).StructScan(&adoption)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Adoption{}, fmt.Errorf("%w: id=%d", ErrAdoptionNotFound, id)
+		}
+		return Adoption{}, adoptionError(err, animalID, "failed to update adoption")
+	}
+	return adoption, nil
+}
+
+func (r *PostgresAdoptionRepository) ListAdoptions(animalID int64) ([]Adoption, error) {
+	adoptions := []Adoption{}
+	err := r.db.Select(&adoptions, `SELECT `+adoptionColumns+` FROM adoptions
+		WHERE animal_id = $1 ORDER BY adopted_on, id`, animalID)
+	if err != nil {
+		return nil, fmt.Errorf("ListAdoptions query error: %w", err)
+	}
+	return adoptions, nil
+}
+
+func (r *PostgresAdoptionRepository) GetAdoption(animalID, id int64) (Adoption, error) {
+	var adoption Adoption
+	err := r.db.Get(&adoption, `SELECT `+adoptionColumns+` FROM adoptions d
+		WHERE d.id = $1 AND d.animal_id = $2 AND `+liveAdoption, id, animalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Adoption{}, fmt.Errorf("%w: id=%d", ErrAdoptionNotFound, id)
+		}
+		return Adoption{}, fmt.Errorf("error getting adoption: %w", err)
+	}
+	return adoption, nil
+}
+
+func (r *PostgresAdoptionRepository) DeleteAdoption(animalID, id int64) error {
+	res, err := r.db.Exec(`DELETE FROM adoptions d
+		WHERE d.id = $1 AND d.animal_id = $2 AND `+liveAdoption, id, animalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete adoption: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrAdoptionNotFound, id)
+	}
+	return nil
+}
+
+// adoptionError translates the constraint violations of a write on adoptions
+// into domain errors. Other errors are wrapped with message.
+func adoptionError(err error, animalID int64, message string) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+	switch {
+	case pqErr.Code == exclusionViolation:
+		return fmt.Errorf("%w: id=%d", ErrAlreadyAdopted, animalID)
+	case pqErr.Constraint == "adoptions_owner_id_fkey":
+		return ErrUnknownOwner
+	case pqErr.Constraint == "adoptions_returned_after_adopted":
+		return ErrReturnBeforeAdoption
+	case pqErr.Code == foreignKeyViolation:
+		// a purge between the select and the insert trips the animal's foreign key
+		return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, animalID)
+	default:
+		return fmt.Errorf("%s: %w", message, err)
+	}
+}
@@ -0,0 +1,146 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdoptionHandler struct {
+	module  Module
+	repo    AdoptionRepository
+	animals AnimalRepository
+}
+
+func NewAdoptionHandler(module Module, repo AdoptionRepository, animals AnimalRepository) *AdoptionHandler {
+	return &AdoptionHandler{module: module, repo: repo, animals: animals}
+}
+
+// bindAdoption reads the body of a create or update request.
+func bindAdoption(ctx *gin.Context) (AdoptionRequest, error) {
+	var req AdoptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return AdoptionRequest{}, bindingError(err)
+	}
+	// dates in DateLayout sort like strings
+	if req.ReturnedOn != nil && *req.ReturnedOn < req.AdoptedOn {
+		return AdoptionRequest{}, ErrReturnBeforeAdoption
+	}
+	return req, nil
+}
+
+// CreateAdoptionHandler records that an owner adopted the animal. It fails with
+// 409 when the animal was adopted by anyone during part of the same period.
+func (h *AdoptionHandler) CreateAdoptionHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	req, err := bindAdoption(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	adoption, err := h.repo.CreateAdoption(id, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/animals/%d/adoptions/%d", id, adoption.ID))
+	ctx.JSON(http.StatusCreated, adoption)
+}
+
+func (h *AdoptionHandler) ListAdoptionsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	adoptions, err := h.repo.ListAdoptions(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": adoptions})
+}
+
+func (h *AdoptionHandler) GetAdoptionHandler(ctx *gin.Context) {
+	id, adoptionID, err := parseAdoptionID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	adoption, err := h.repo.GetAdoption(id, adoptionID)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, adoption)
+}
+
+// UpdateAdoptionHandler replaces an adoption, which is how the return of an
+// animal is recorded.
+func (h *AdoptionHandler) UpdateAdoptionHandler(ctx *gin.Context) {
+	id, adoptionID, err := parseAdoptionID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	req, err := bindAdoption(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	adoption, err := h.repo.UpdateAdoption(id, adoptionID, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, adoption)
+}
+
+func (h *AdoptionHandler) DeleteAdoptionHandler(ctx *gin.Context) {
+	id, adoptionID, err := parseAdoptionID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteAdoption(id, adoptionID); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Adoption deleted successfully"})
+}
+
+// parseAdoptionID reads the ids of the animal and of one of its adoptions.
+func parseAdoptionID(ctx *gin.Context) (int64, int64, error) {
+	id, err := parseID(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	adoptionID, err := strconv.ParseInt(ctx.Param("adoption_id"), 10, 64)
+	if err != nil {
+		return 0, 0, badRequest("adoption id must be an integer")
+	}
+	return id, adoptionID, nil
+}
@@ -0,0 +1,127 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// attachmentColumns is the select list that matches the Attachment struct.
+const attachmentColumns = `id, animal_id, filename, content_type, size, checksum, storage_key, created_at`
+
+// AttachmentRepository keeps the metadata of attachments. Attachments of a soft
+// deleted animal are hidden, and purging the animal removes them.
+type AttachmentRepository interface {
+	// CreateAttachment fails with ErrAnimalNotFound when the animal does not exist.
+	CreateAttachment(a Attachment) (Attachment, error)
+	ListAttachments(animalID int64) ([]Attachment, error)
+	GetAttachment(animalID, id int64) (Attachment, error)
+	DeleteAttachment(animalID, id int64) error
+	// PendingBlobDeletions returns up to limit storage keys of deleted attachments
+	// whose blob still has to be deleted, those that never failed first. A key
+	// whose deletion failed is left out until its retry delay has passed.
+	PendingBlobDeletions(limit int) ([]string, error)
+	// BlobDeleted records that the blob stored under key is gone.
+	BlobDeleted(key string) error
+	// BlobDeleteFailed records a failed attempt to delete the blob stored under
+	// key, which doubles the delay before the next one.
+	BlobDeleteFailed(key string) error
+}
+
+type PostgresAttachmentRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresAttachmentRepository(db *sqlx.DB) *PostgresAttachmentRepository {
+	return &PostgresAttachmentRepository{db: db}
+}
+
+func (r *PostgresAttachmentRepository) CreateAttachment(a Attachment) (Attachment, error) {
+	var created Attachment
+	err := r.db.QueryRowx(`INSERT INTO animal_attachments (animal_id, filename, content_type, size, checksum, storage_key)
+		SELECT id, $2, $3, $4, $5, $6 FROM animals WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+attachmentColumns,
+		a.AnimalID, a.Filename, a.ContentType, a.Size, a.Checksum, a.StorageKey).StructScan(&created)
+	if err != nil {
+		// a purge between the select and the insert trips the foreign key instead
+		if errors.Is(err, sql.ErrNoRows) || isViolation(err, foreignKeyViolation) {
+			return Attachment{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, a.AnimalID)
+		}
+		return Attachment{}, fmt.Errorf("failed to insert attachment: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PostgresAttachmentRepository) ListAttachments(animalID int64) ([]Attachment, error) {
+	attachments := []Attachment{}
+	err := r.db.Select(&attachments, `SELECT `+attachmentColumns+` FROM animal_attachments
+		WHERE animal_id = $1 ORDER BY id`, animalID)
+	if err != nil {
+		return nil, fmt.Errorf("ListAttachments query error: %w", err)
+	}
+	return attachments, nil
+}
+
+func (r *PostgresAttachmentRepository) GetAttachment(animalID, id int64) (Attachment, error) {
+	var attachment Attachment
+	err := r.db.Get(&attachment, `SELECT `+attachmentColumns+` FROM animal_attachments t
+		WHERE t.id = $1 AND t.animal_id = $2
+		AND EXISTS (SELECT 1 FROM animals a WHERE a.id = t.animal_id AND a.deleted_at IS NULL)`, id, animalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Attachment{}, fmt.Errorf("%w: id=%d", ErrAttachmentNotFound, id)
+		}
+		return Attachment{}, fmt.Errorf("error getting attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (r *PostgresAttachmentRepository) DeleteAttachment(animalID, id int64) error {
+	res, err := r.db.Exec(`DELETE FROM animal_attachments t
+		WHERE t.id = $1 AND t.animal_id = $2
+		AND EXISTS (SELECT 1 FROM animals a WHERE a.id = t.animal_id AND a.deleted_at IS NULL)`, id, animalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrAttachmentNotFound, id)
+	}
+	return nil
+}
+
+// blobRetryDelay is how long a failed blob deletion waits before it is retried:
+// a minute after the first failure, doubling up to a day.
+const blobRetryDelay = `least(interval '1 minute' * power(2, attempts - 1), interval '1 day')`
+
+func (r *PostgresAttachmentRepository) PendingBlobDeletions(limit int) ([]string, error) {
+	var keys []string
+	err := r.db.Select(&keys, `SELECT storage_key FROM attachment_blob_deletions
+		WHERE last_attempt_at IS NULL OR last_attempt_at + `+blobRetryDelay+` <= now()
+		ORDER BY attempts, queued_at LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("PendingBlobDeletions query error: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *PostgresAttachmentRepository) BlobDeleted(key string) error {
+	if _, err := r.db.Exec(`DELETE FROM attachment_blob_deletions WHERE storage_key = $1`, key); err != nil {
+		return fmt.Errorf("failed to dequeue blob deletion: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresAttachmentRepository) BlobDeleteFailed(key string) error {
+	_, err := r.db.Exec(`UPDATE attachment_blob_deletions SET attempts = attempts + 1, last_attempt_at = now()
+		WHERE storage_key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("failed to record blob deletion attempt: %w", err)
+	}
+	return nil
+}
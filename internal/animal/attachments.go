@@ -0,0 +1,286 @@
+package animal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MaxAttachmentSize is the largest file that can be attached, 10 MiB.
+	MaxAttachmentSize = 10 << 20
+	// multipartOverhead leaves room for the boundaries and part headers around the file.
+	multipartOverhead = 64 << 10
+	// sniffLength is how much of a file http.DetectContentType looks at.
+	sniffLength = 512
+)
+
+// attachmentContentTypes are the types a file may have, as detected from its
+// content. The Content-Type the client sends for the part is not trusted.
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+type AttachmentHandler struct {
+	module  Module
+	repo    AttachmentRepository
+	animals AnimalRepository
+	blobs   blob.Store
+}
+
+func NewAttachmentHandler(module Module, repo AttachmentRepository, animals AnimalRepository, blobs blob.Store) *AttachmentHandler {
+	return &AttachmentHandler{module: module, repo: repo, animals: animals, blobs: blobs}
+}
+
+// UploadAttachmentHandler stores the multipart form field "file" and attaches it
+// to the animal.
+func (h *AttachmentHandler) UploadAttachmentHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, MaxAttachmentSize+multipartOverhead)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		h.respondError(ctx, uploadError(err))
+		return
+	}
+	if header.Size > MaxAttachmentSize {
+		h.respondError(ctx, fmt.Errorf("%w: files must not be larger than %d bytes", ErrPayloadTooLarge, MaxAttachmentSize))
+		return
+	}
+	if header.Size == 0 {
+		h.respondError(ctx, badRequest("the file is empty"))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.respondError(ctx, fmt.Errorf("failed to open uploaded file: %w", err))
+		return
+	}
+	defer file.Close()
+
+	sniffed := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, sniffed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		h.respondError(ctx, fmt.Errorf("failed to read uploaded file: %w", err))
+		return
+	}
+	contentType := http.DetectContentType(sniffed[:n])
+	if !attachmentContentTypes[contentType] {
+		h.respondError(ctx, fmt.Errorf("%w: %s files cannot be attached, only JPEG, PNG, GIF, WebP images and PDFs", ErrUnsupportedMediaType, contentType))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		h.respondError(ctx, fmt.Errorf("failed to rewind uploaded file: %w", err))
+		return
+	}
+
+	key, err := attachmentKey(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	hash := sha256.New()
+	if err := h.blobs.Put(key, io.TeeReader(file, hash), header.Size, contentType); err != nil {
+		h.respondError(ctx, fmt.Errorf("failed to store attachment: %w", err))
+		return
+	}
+
+	attachment, err := h.repo.CreateAttachment(Attachment{
+		AnimalID:    id,
+		Filename:    attachmentFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+	})
+	if err != nil {
+		if delErr := h.blobs.Delete(key); delErr != nil {
+			h.module.NewTransactionLogger(ctx).Error("failed to delete orphaned attachment blob", "error", delErr, "key", key)
+		}
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/animals/%d/attachments/%d", id, attachment.ID))
+	ctx.JSON(http.StatusCreated, attachment)
+}
+
+// uploadError translates a failure to read the multipart form into a domain error.
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return fmt.Errorf("%w: files must not be larger than %d bytes", ErrPayloadTooLarge, MaxAttachmentSize)
+	case errors.Is(err, http.ErrNotMultipart):
+		return fmt.Errorf("%w: the file must be sent as multipart/form-data", ErrUnsupportedMediaType)
+	case errors.Is(err, http.ErrMissingFile):
+		return badRequest("the multipart form has no \"file\" field")
+	default:
+		return badRequest("malformed multipart form: %v", err)
+	}
+}
+
+// attachmentKey returns a new, unguessable blob key for a file of the animal.
+func attachmentKey(animalID int64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+	return fmt.Sprintf("animals/%d/%s", animalID, hex.EncodeToString(random)), nil
+}
+
+// attachmentFilename cleans up the name the client gave the file, which is only
+// used to suggest a name on download.
+func attachmentFilename(name string) string {
+	name = strings.TrimSpace(strings.ToValidUTF8(name, ""))
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if utf8.RuneCountInString(name) > 255 {
+		name = string([]rune(name)[:255])
+	}
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+func (h *AttachmentHandler) ListAttachmentsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	attachments, err := h.repo.ListAttachments(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// DownloadAttachmentHandler streams the content of an attachment. Since the
+// content never changes, its checksum serves as the entity tag.
+func (h *AttachmentHandler) DownloadAttachmentHandler(ctx *gin.Context) {
+	attachment, ok := h.attachment(ctx)
+	if !ok {
+		return
+	}
+
+	if writeValidators(ctx, `"`+attachment.Checksum+`"`, attachment.CreatedAt) {
+		return
+	}
+
+	content, err := h.blobs.Get(attachment.StorageKey)
+	if err != nil {
+		h.respondError(ctx, fmt.Errorf("failed to read attachment %d: %w", attachment.ID, err))
+		return
+	}
+	defer content.Close()
+
+	ctx.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// DeleteAttachmentHandler removes an attachment. Its blob is deleted later by
+// SweepAttachmentBlobs.
+func (h *AttachmentHandler) DeleteAttachmentHandler(ctx *gin.Context) {
+	attachment, ok := h.attachment(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteAttachment(attachment.AnimalID, attachment.ID); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// attachment loads the attachment addressed by the path. On failure the problem
+// has already been written.
+func (h *AttachmentHandler) attachment(ctx *gin.Context) (Attachment, bool) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return Attachment{}, false
+	}
+	attachmentID, err := strconv.ParseInt(ctx.Param("attachment_id"), 10, 64)
+	if err != nil {
+		h.respondError(ctx, badRequest("attachment id must be an integer"))
+		return Attachment{}, false
+	}
+
+	attachment, err := h.repo.GetAttachment(id, attachmentID)
+	if err != nil {
+		h.respondError(ctx, err)
+		return Attachment{}, false
+	}
+	return attachment, true
+}
+
+// SweepAttachmentBlobs deletes the blobs of deleted attachments, including those
+// removed along with a purged animal, and returns how many it deleted. A blob
+// that cannot be deleted is logged and stays queued, to be retried after a
+// delay, without holding up the others.
+func SweepAttachmentBlobs(repo AttachmentRepository, blobs blob.Store, logger *slog.Logger) (int, error) {
+	keys, err := repo.PendingBlobDeletions(100)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if err := blobs.Delete(key); err != nil {
+			logger.Error("failed to delete attachment blob", "error", err, "key", key)
+			if err := repo.BlobDeleteFailed(key); err != nil {
+				return deleted, err
+			}
+			continue
+		}
+		if err := repo.BlobDeleted(key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,136 @@
+package animal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const MaxBatchSize = 1000
+
+// batchDocument is the shape of a best-effort batch report.
+var batchDocument = document{root: "batch", records: "results", columns: []string{"index", "status", "animal", "error"}}
+
+// BatchItemResult reports the outcome of one item of a best-effort batch.
+type BatchItemResult struct {
+	Index  int              `json:"index"`
+	Status int              `json:"status"`
+	Animal *Animal          `json:"animal,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+// BatchCreateResponse is the 207 Multi-Status body of a best-effort batch.
+type BatchCreateResponse struct {
+	Results []BatchItemResult `json:"results"`
+}
+
+// CollectionActionHandler serves the custom methods on the animals collection,
+// written as POST /animals:<action>.
+func (h *AnimalHandler) CollectionActionHandler(ctx *gin.Context) {
+	switch ctx.Param("action") {
+	case ":batch":
+		h.BatchCreateAnimalsHandler(ctx)
+	default:
+		problem.NoRoute(ctx)
+	}
+}
+
+// BatchCreateAnimalsHandler creates every animal of an array, sent in any of the
+// formats of bindBody. With atomic=true either all items are created (201) or
+// none (422 listing the invalid fields per index). Otherwise every item that is
+// valid and refers to existing resources is created, and a 207 reports each item.
+func (h *AnimalHandler) BatchCreateAnimalsHandler(ctx *gin.Context) {
+	atomic, err := strconv.ParseBool(ctx.DefaultQuery("atomic", "false"))
+	if err != nil {
+		h.respondError(ctx, badRequest("atomic must be true or false"))
+		return
+	}
+
+	body, err := requestJSON(ctx, reflect.TypeOf([]AnimalCreateRequest{}))
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		h.respondError(ctx, badRequest("the body must be an array of animals"))
+		return
+	}
+	if len(items) == 0 || len(items) > MaxBatchSize {
+		h.respondError(ctx, badRequest("a batch must contain between 1 and %d items", MaxBatchSize))
+		return
+	}
+
+	var (
+		reqs    = make([]AnimalCreateRequest, 0, len(items))
+		indexes = make([]int, 0, len(items))
+		results = make([]BatchItemResult, len(items))
+		invalid []problem.FieldError
+	)
+	for i, raw := range items {
+		var req AnimalCreateRequest
+		if err := binding.JSON.BindBody(raw, &req); err != nil {
+			err = bindingError(err)
+			p := ProblemForError(err)
+			results[i] = BatchItemResult{Index: i, Status: p.Status, Error: &p}
+			invalid = append(invalid, indexedFieldErrors(i, err)...)
+			continue
+		}
+		reqs = append(reqs, req)
+		indexes = append(indexes, i)
+	}
+
+	if atomic && len(reqs) < len(items) {
+		h.respondError(ctx, &ValidationError{Fields: invalid})
+		return
+	}
+
+	if atomic {
+		created, err := h.repo.CreateAnimals(reqs)
+		if err != nil {
+			h.respondError(ctx, err)
+			return
+		}
+		render(ctx, h.module, http.StatusCreated, animalListDocument(Projection{}), gin.H{"data": created})
+		return
+	}
+
+	if len(reqs) > 0 {
+		created, errs, err := h.repo.CreateEachAnimal(reqs)
+		if err != nil {
+			h.respondError(ctx, err)
+			return
+		}
+		for n, i := range indexes {
+			if errs[n] != nil {
+				p := ProblemForError(errs[n])
+				results[i] = BatchItemResult{Index: i, Status: p.Status, Error: &p}
+				continue
+			}
+			results[i] = BatchItemResult{Index: i, Status: http.StatusCreated, Animal: &created[n]}
+		}
+	}
+	render(ctx, h.module, http.StatusMultiStatus, batchDocument, BatchCreateResponse{Results: results})
+}
+
+// indexedFieldErrors prefixes the field errors of batch item i with its index.
+func indexedFieldErrors(i int, err error) []problem.FieldError {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return []problem.FieldError{{Field: fmt.Sprintf("[%d]", i), Code: "invalid", Message: err.Error()}}
+	}
+
+	fields := make([]problem.FieldError, len(verr.Fields))
+	for n, f := range verr.Fields {
+		f.Field = fmt.Sprintf("[%d].%s", i, f.Field)
+		fields[n] = f
+	}
+	return fields
+}
@@ -0,0 +1,130 @@
+package animal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// animalETag is the strong entity tag of the representation of an animal that
+// render sends for ctx with projection p. It is the version of the animal, and
+// for any representation but full JSON also a hash of its media type and fields,
+// so that each representation has a tag of its own.
+func animalETag(ctx *gin.Context, a Animal, p Projection) string {
+	tag := strconv.FormatInt(a.Version, 10)
+	f, err := negotiateFormat(ctx.GetHeader("Accept"))
+	if err != nil {
+		f = formatJSON
+	}
+	if f != formatJSON || p.Fields != nil {
+		variant := sha256.Sum256([]byte(f.mediaTypes[0] + ";" + strings.Join(p.members(), ",")))
+		tag += "." + hex.EncodeToString(variant[:4])
+	}
+	return `"` + tag + `"`
+}
+
+// setAnimalETag exposes the entity tag of a full animal representation.
+func setAnimalETag(ctx *gin.Context, a Animal) {
+	ctx.Header("ETag", animalETag(ctx, a, Projection{}))
+}
+
+// listETag is a weak entity tag for a page of animals. It changes whenever an
+// animal joins or leaves the page or one of them is written.
+func listETag(page AnimalPage, nextCursor *string) string {
+	h := sha256.New()
+	for _, a := range page.Animals {
+		fmt.Fprintf(h, "%d:%d;", a.ID, a.Version)
+	}
+	if nextCursor != nil {
+		h.Write([]byte(*nextCursor))
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// lastModified returns the latest update time of the given animals.
+func lastModified(animals ...Animal) time.Time {
+	var latest time.Time
+	for _, a := range animals {
+		if a.UpdatedAt.After(latest) {
+			latest = a.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// writeValidators sets ETag and Last-Modified and reports whether the request's
+// If-None-Match or If-Modified-Since header shows the client's copy is current,
+// in which case a 304 Not Modified has been written. A zero modTime is omitted.
+func writeValidators(ctx *gin.Context, etag string, modTime time.Time) bool {
+	ctx.Header("ETag", etag)
+	if !modTime.IsZero() {
+		ctx.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	// If-None-Match takes precedence; If-Modified-Since is only evaluated without it
+	notModified := false
+	if inm := ctx.GetHeader("If-None-Match"); inm != "" {
+		notModified = etagListMatches(inm, etag)
+	} else if ims := ctx.GetHeader("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		if since, err := http.ParseTime(ims); err == nil {
+			notModified = !modTime.Truncate(time.Second).After(since)
+		}
+	}
+
+	if notModified {
+		ctx.Status(http.StatusNotModified)
+		ctx.Writer.WriteHeaderNow()
+	}
+	return notModified
+}
+
+// etagListMatches performs the weak comparison of If-None-Match.
+func etagListMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	current := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version required by the If-Match header, or 0 when
+// the request is unconditional. PUT, PATCH and DELETE must send the header, so
+// that a lost update takes a deliberate If-Match: *. The tag of any
+// representation of the animal matches while its version is current. Weak tags
+// never match, as RFC 9110 requires a strong comparison for If-Match.
+func ifMatchVersion(ctx *gin.Context) (int64, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		switch ctx.Request.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			return 0, ErrIfMatchRequired
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, badRequest("If-Match must contain a single entity tag")
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	tag, closed := strings.CutSuffix(tag, `"`)
+	tag, _, _ = strings.Cut(tag, ".")
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if !ok || !closed || err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: If-Match %s does not match the current entity tag", ErrVersionConflict, header)
+	}
+	return version, nil
+}
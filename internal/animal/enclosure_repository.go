@@ -0,0 +1,315 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// enclosureColumns is the select list that matches the Enclosure struct.
+const enclosureColumns = `id, name, capacity,
+	ARRAY(SELECT species_id FROM enclosure_species s WHERE s.enclosure_id = enclosures.id ORDER BY species_id) AS allowed_species,
+	created_at, updated_at`
+
+type EnclosureRepository interface {
+	CreateEnclosure(r EnclosureRequest) (Enclosure, error)
+	// UpdateEnclosure fails with ErrEnclosureFull or ErrSpeciesNotAllowed when
+	// the animals the enclosure houses would no longer fit in it.
+	UpdateEnclosure(id int64, r EnclosureRequest) (Enclosure, error)
+	ListEnclosures(opts EnclosureListOptions) (EnclosurePage, error)
+	GetEnclosure(id int64) (Enclosure, error)
+	// DeleteEnclosure fails with ErrEnclosureOccupied while it houses animals.
+	DeleteEnclosure(id int64) error
+	// Occupancy reports how full every enclosure is.
+	Occupancy() (OccupancyReport, error)
+}
+
+type PostgresEnclosureRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresEnclosureRepository(db *sqlx.DB) *PostgresEnclosureRepository {
+	return &PostgresEnclosureRepository{db: db}
+}
+
+// allowedSpecies renders a query of the ids of the taxa the enclosure given by
+// the placeholder takes: the ones listed for it and every taxon below them.
+func allowedSpecies(enclosure string) string {
+	return `WITH RECURSIVE allowed AS (
+		SELECT species_id AS id FROM enclosure_species WHERE enclosure_id = ` + enclosure + `
+		UNION
+		SELECT s.id FROM species s JOIN allowed a ON s.parent_id = a.id
+	) SELECT id FROM allowed`
+}
+
+func (r *PostgresEnclosureRepository) CreateEnclosure(req EnclosureRequest) (Enclosure, error) {
+	var enclosure Enclosure
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		var id int64
+		err := tx.Get(&id, `INSERT INTO enclosures (name, capacity) VALUES ($1, $2) RETURNING id`, req.Name, req.Capacity)
+		if err != nil {
+			return err
+		}
+		if err := setAllowedSpecies(tx, id, req.AllowedSpecies); err != nil {
+			return err
+		}
+		return tx.Get(&enclosure, `SELECT `+enclosureColumns+` FROM enclosures WHERE id = $1`, id)
+	})
+	if err != nil {
+		return Enclosure{}, enclosureWriteError(err, req, "failed to insert enclosure")
+	}
+	return enclosure, nil
+}
+
+func (r *PostgresEnclosureRepository) UpdateEnclosure(id int64, req EnclosureRequest) (Enclosure, error) {
+	var enclosure Enclosure
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		// the update locks the row, which keeps animals from moving in until the
+		// new limits are checked, see takePlace
+		res, err := tx.Exec(`UPDATE enclosures SET name = $1, capacity = $2, updated_at = now() WHERE id = $3`,
+			req.Name, req.Capacity, id)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return fmt.Errorf("%w: id=%d", ErrEnclosureNotFound, id)
+		}
+		if err := setAllowedSpecies(tx, id, req.AllowedSpecies); err != nil {
+			return err
+		}
+		if err := checkOccupants(tx, id, req); err != nil {
+			return err
+		}
+		return tx.Get(&enclosure, `SELECT `+enclosureColumns+` FROM enclosures WHERE id = $1`, id)
+	})
+	if err != nil {
+		return Enclosure{}, enclosureWriteError(err, req, "failed to update enclosure")
+	}
+	return enclosure, nil
+}
+
+// setAllowedSpecies replaces the taxa enclosure id takes.
+func setAllowedSpecies(tx *sqlx.Tx, id int64, species []int64) error {
+	if _, err := tx.Exec(`DELETE FROM enclosure_species WHERE enclosure_id = $1`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO enclosure_species (enclosure_id, species_id)
+		SELECT $1, species_id FROM unnest($2::bigint[]) AS species_id ON CONFLICT DO NOTHING`, id, pq.Array(species))
+	return err
+}
+
+// checkOccupants makes sure the animals enclosure id houses still fit in it
+// after it was changed by req.
+func checkOccupants(tx *sqlx.Tx, id int64, req EnclosureRequest) error {
+	var occupants int
+	if err := tx.Get(&occupants, `SELECT count(*) FROM animals WHERE enclosure_id = $1 AND deleted_at IS NULL`, id); err != nil {
+		return fmt.Errorf("failed to count animals in enclosure: %w", err)
+	}
+	if occupants > req.Capacity {
+		return fmt.Errorf("%w: enclosure %d houses %d animals, more than a capacity of %d", ErrEnclosureFull, id, occupants, req.Capacity)
+	}
+	if len(req.AllowedSpecies) == 0 {
+		return nil
+	}
+
+	var misfits []int64
+	err := tx.Select(&misfits, `SELECT id FROM animals WHERE enclosure_id = $1 AND deleted_at IS NULL
+		AND (species_id IS NULL OR species_id NOT IN (`+allowedSpecies("$1")+`)) ORDER BY id`, id)
+	if err != nil {
+		return fmt.Errorf("failed to check species in enclosure: %w", err)
+	}
+	if len(misfits) > 0 {
+		return fmt.Errorf("%w: enclosure %d houses animals of other or unknown species, ids %v", ErrSpeciesNotAllowed, id, misfits)
+	}
+	return nil
+}
+
+// enclosureWriteError translates a failed enclosure write into a domain error.
+func enclosureWriteError(err error, req EnclosureRequest, message string) error {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrConflict):
+		return err
+	case isViolation(err, uniqueViolation):
+		return fmt.Errorf("%w: an enclosure named %q already exists", ErrConflict, req.Name)
+	case isViolation(err, foreignKeyViolation):
+		return &ValidationError{Fields: []problem.FieldError{{Field: "allowed_species", Code: "unknown", Message: "must only reference existing species"}}}
+	default:
+		return fmt.Errorf("%s: %w", message, err)
+	}
+}
+
+func (r *PostgresEnclosureRepository) ListEnclosures(opts EnclosureListOptions) (EnclosurePage, error) {
+	enclosures := []Enclosure{}
+	err := r.db.Select(&enclosures, `SELECT `+enclosureColumns+` FROM enclosures WHERE id > $1 ORDER BY id LIMIT $2`,
+		opts.AfterID, opts.Limit+1)
+	if err != nil {
+		return EnclosurePage{}, fmt.Errorf("ListEnclosures query error: %w", err)
+	}
+
+	page := EnclosurePage{Enclosures: enclosures}
+	if len(enclosures) > opts.Limit {
+		page.Enclosures, page.HasMore = enclosures[:opts.Limit], true
+	}
+	return page, nil
+}
+
+func (r *PostgresEnclosureRepository) GetEnclosure(id int64) (Enclosure, error) {
+	var enclosure Enclosure
+	err := r.db.Get(&enclosure, `SELECT `+enclosureColumns+` FROM enclosures WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Enclosure{}, fmt.Errorf("%w: id=%d", ErrEnclosureNotFound, id)
+		}
+		return Enclosure{}, fmt.Errorf("error getting enclosure: %w", err)
+	}
+	return enclosure, nil
+}
+
+func (r *PostgresEnclosureRepository) DeleteEnclosure(id int64) error {
+	res, err := r.db.Exec(`DELETE FROM enclosures WHERE id = $1`, id)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			return fmt.Errorf("%w: id=%d", ErrEnclosureOccupied, id)
+		}
+		return fmt.Errorf("failed to delete enclosure: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrEnclosureNotFound, id)
+	}
+	return nil
+}
+
+func (r *PostgresEnclosureRepository) Occupancy() (OccupancyReport, error) {
+	var report OccupancyReport
+	report.Data = []Occupancy{}
+	err := r.db.Select(&report.Data, `SELECT e.id AS enclosure_id, e.name, e.capacity,
+			count(a.id) AS occupants, e.capacity - count(a.id) AS free
+		FROM enclosures e LEFT JOIN animals a ON a.enclosure_id = e.id AND a.deleted_at IS NULL
+		GROUP BY e.id ORDER BY e.id`)
+	if err != nil {
+		return OccupancyReport{}, fmt.Errorf("Occupancy query error: %w", err)
+	}
+	err = r.db.Get(&report.Totals.Unhoused, `SELECT count(*) FROM animals
+		WHERE enclosure_id IS NULL AND deleted_at IS NULL AND status <> ALL($1)`, pq.Array(unhousedStatuses))
+	if err != nil {
+		return OccupancyReport{}, fmt.Errorf("Occupancy query error: %w", err)
+	}
+
+	for _, o := range report.Data {
+		report.Totals.Capacity += o.Capacity
+		report.Totals.Occupants += o.Occupants
+		report.Totals.Free += o.Free
+	}
+	return report, nil
+}
+
+func (r *PostgresAnimalRepository) MoveAnimal(id int64, expectedVersion int64, req MoveRequest) (Animal, error) {
+	var animal Animal
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		var current Animal
+		err := tx.Get(&current, `SELECT `+animalColumns+` FROM `+liveAnimals+` WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+			}
+			return fmt.Errorf("error getting animal: %w", err)
+		}
+		if expectedVersion > 0 && current.Version != expectedVersion {
+			return fmt.Errorf("%w: id=%d", ErrVersionConflict, id)
+		}
+
+		target := req.EnclosureID
+		if target != nil && (current.EnclosureID == nil || *current.EnclosureID != *target) {
+			if slices.Contains(unhousedStatuses, current.Status) {
+				return fmt.Errorf("%w: animal %d is %s", ErrNotHoused, id, current.Status)
+			}
+			if err := takePlace(tx, *target, current); err != nil {
+				return err
+			}
+		}
+
+		err = tx.QueryRowx(withRevision(OpMove, `UPDATE animals SET enclosure_id = $2, version = version + 1, updated_at = now()
+			WHERE id = $1 RETURNING *`), id, target).StructScan(&animal)
+		if err != nil {
+			return fmt.Errorf("failed to move animal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Animal{}, err
+	}
+	return animal, nil
+}
+
+// takePlace checks that the enclosure can take the animal. It locks the
+// enclosure row first, so concurrent moves into the same enclosure, and changes
+// to its capacity, wait for each other instead of all counting the same free
+// place. Leaving an enclosure only frees a place, so the enclosure the animal
+// comes from is not locked, and a move never holds more than one enclosure lock.
+func takePlace(tx *sqlx.Tx, enclosureID int64, animal Animal) error {
+	var capacity int
+	err := tx.Get(&capacity, `SELECT capacity FROM enclosures WHERE id = $1 FOR UPDATE`, enclosureID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownEnclosure
+		}
+		return fmt.Errorf("failed to lock enclosure: %w", err)
+	}
+
+	var occupants int
+	err = tx.Get(&occupants, `SELECT count(*) FROM animals WHERE enclosure_id = $1 AND deleted_at IS NULL`, enclosureID)
+	if err != nil {
+		return fmt.Errorf("failed to count animals in enclosure: %w", err)
+	}
+	if occupants >= capacity {
+		return fmt.Errorf("%w: enclosure %d houses %d of %d animals", ErrEnclosureFull, enclosureID, occupants, capacity)
+	}
+
+	return checkSpecies(tx, enclosureID, animal)
+}
+
+// keepPlace checks that the enclosure an animal lives in still takes it after
+// its species was changed. Like takePlace it locks the enclosure first, so a
+// concurrent change of the species the enclosure takes either sees the new
+// species of the animal or is seen by the check.
+func keepPlace(tx *sqlx.Tx, animal Animal) error {
+	if animal.EnclosureID == nil {
+		return nil
+	}
+	if _, err := tx.Exec(`SELECT 1 FROM enclosures WHERE id = $1 FOR UPDATE`, *animal.EnclosureID); err != nil {
+		return fmt.Errorf("failed to lock enclosure: %w", err)
+	}
+	return checkSpecies(tx, *animal.EnclosureID, animal)
+}
+
+// checkSpecies makes sure the enclosure takes the species of the animal. An
+// enclosure without allowed species takes any animal, one with allowed species
+// only animals of a known species.
+func checkSpecies(tx *sqlx.Tx, enclosureID int64, animal Animal) error {
+	var restricted, allowed bool
+	err := tx.QueryRowx(`SELECT EXISTS (SELECT 1 FROM enclosure_species WHERE enclosure_id = $1),
+		COALESCE($2 IN (`+allowedSpecies("$1")+`), false)`, enclosureID, animal.SpeciesID).Scan(&restricted, &allowed)
+	if err != nil {
+		return fmt.Errorf("failed to check species of enclosure: %w", err)
+	}
+	switch {
+	case !restricted:
+		return nil
+	case animal.SpeciesID == nil:
+		return fmt.Errorf("%w: enclosure %d only takes some species and animal %d has none", ErrSpeciesRequired, enclosureID, animal.ID)
+	case !allowed:
+		return fmt.Errorf("%w: enclosure %d does not take the species of animal %d", ErrSpeciesNotAllowed, enclosureID, animal.ID)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enclosureListParams are the query parameters accepted by GET /enclosures.
+var enclosureListParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+}
+
+type EnclosureHandler struct {
+	module  Module
+	repo    EnclosureRepository
+	animals AnimalRepository
+}
+
+func NewEnclosureHandler(module Module, repo EnclosureRepository, animals AnimalRepository) *EnclosureHandler {
+	return &EnclosureHandler{module: module, repo: repo, animals: animals}
+}
+
+func (h *EnclosureHandler) CreateEnclosureHandler(ctx *gin.Context) {
+	var req EnclosureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	enclosure, err := h.repo.CreateEnclosure(req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/enclosures/%d", enclosure.ID))
+	ctx.JSON(http.StatusCreated, enclosure)
+}
+
+// UpdateEnclosureHandler replaces an enclosure. It fails with 409 when the
+// animals it houses would exceed the new capacity or are of species it would
+// no longer take.
+func (h *EnclosureHandler) UpdateEnclosureHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req EnclosureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	enclosure, err := h.repo.UpdateEnclosure(id, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enclosure)
+}
+
+func (h *EnclosureHandler) ListEnclosuresHandler(ctx *gin.Context) {
+	for param := range ctx.Request.URL.Query() {
+		if !enclosureListParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parsePageParams(ctx, nil)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts := EnclosureListOptions{Limit: limit}
+	if after != nil {
+		opts.AfterID = after.ID
+	}
+
+	page, err := h.repo.ListEnclosures(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if page.HasMore {
+		token := EncodeCursor(Cursor{ID: page.Enclosures[len(page.Enclosures)-1].ID})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, EnclosureListResponse{Data: page.Enclosures, NextCursor: nextCursor})
+}
+
+func (h *EnclosureHandler) GetEnclosureHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	enclosure, err := h.repo.GetEnclosure(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enclosure)
+}
+
+func (h *EnclosureHandler) DeleteEnclosureHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteEnclosure(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Enclosure deleted successfully"})
+}
+
+// ListEnclosureAnimalsHandler lists the animals an enclosure houses. It accepts
+// the query parameters of GET /animals.
+func (h *EnclosureHandler) ListEnclosureAnimalsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetEnclosure(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx, false)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts.Enclosure = &id
+
+	page, err := h.animals.ListAnimals(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	writeAnimalPage(ctx, h.module, h.animals, opts, page)
+}
+
+// OccupancyHandler reports how many animals every enclosure houses.
+func (h *EnclosureHandler) OccupancyHandler(ctx *gin.Context) {
+	report, err := h.repo.Occupancy()
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// MoveAnimalHandler houses an animal in an enclosure, moving it out of the one
+// it was in, or takes it out of its enclosure when enclosure_id is null. Like
+// other writes it honours If-Match.
+func (h *AnimalHandler) MoveAnimalHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req MoveRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	animal, err := h.repo.MoveAnimal(id, version, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusOK, animalDocument(Projection{}), animal)
+}
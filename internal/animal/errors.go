@@ -0,0 +1,151 @@
+package animal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Error kinds. Domain errors wrap one of these so that handlers can translate
+// them into a response without knowing every individual error.
+var (
+	ErrNotFound             = errors.New("not found")
+	ErrValidation           = errors.New("validation failed")
+	ErrConflict             = errors.New("conflict")
+	ErrBadRequest           = errors.New("invalid request")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("not acceptable")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrPayloadTooLarge      = errors.New("payload too large")
+)
+
+var (
+	ErrAnimalNotFound        = fmt.Errorf("animal %w", ErrNotFound)
+	ErrInvalidID             = fmt.Errorf("%w: invalid id", ErrBadRequest)
+	ErrVersionConflict       = fmt.Errorf("%w: animal was modified", ErrPreconditionFailed)
+	ErrIfMatchRequired       = fmt.Errorf("%w: send the ETag of the animal in If-Match, or * to write unconditionally", ErrPreconditionRequired)
+	ErrAnimalNotDeleted      = fmt.Errorf("%w: animal is not deleted", ErrConflict)
+	ErrRevisionNotFound      = fmt.Errorf("revision %w", ErrNotFound)
+	ErrSpeciesNotFound       = fmt.Errorf("species %w", ErrNotFound)
+	ErrSpeciesInUse          = fmt.Errorf("%w: species is still used by taxa, animals or enclosures", ErrConflict)
+	ErrAttachmentNotFound    = fmt.Errorf("attachment %w", ErrNotFound)
+	ErrTagNotFound           = fmt.Errorf("tag %w", ErrNotFound)
+	ErrAnimalHasOffspring    = fmt.Errorf("%w: animal is the parent of other animals", ErrConflict)
+	ErrMedicalRecordNotFound = fmt.Errorf("medical record %w", ErrNotFound)
+	ErrOwnerNotFound         = fmt.Errorf("owner %w", ErrNotFound)
+	ErrOwnerHasAdoptions     = fmt.Errorf("%w: owner has adoptions", ErrConflict)
+	ErrAdoptionNotFound      = fmt.Errorf("adoption %w", ErrNotFound)
+	ErrAlreadyAdopted        = fmt.Errorf("%w: animal is already adopted during that period", ErrConflict)
+	ErrIllegalTransition     = fmt.Errorf("%w: illegal status transition", ErrConflict)
+	ErrNoActiveAdoption      = fmt.Errorf("%w: animal has no adoption that lasts today", ErrConflict)
+	ErrEnclosureNotFound     = fmt.Errorf("enclosure %w", ErrNotFound)
+	ErrEnclosureFull         = fmt.Errorf("%w: enclosure is full", ErrConflict)
+	ErrEnclosureOccupied     = fmt.Errorf("%w: enclosure still houses animals", ErrConflict)
+	ErrSpeciesNotAllowed     = fmt.Errorf("%w: species is not allowed in the enclosure", ErrConflict)
+	ErrSpeciesRequired       = fmt.Errorf("%w: enclosure only takes animals of a known species", ErrConflict)
+	ErrNotHoused             = fmt.Errorf("%w: animal cannot be housed", ErrConflict)
+	ErrUnknownSpecies        = &ValidationError{Fields: []problem.FieldError{{Field: "species_id", Code: "unknown", Message: "does not reference an existing species"}}}
+	ErrUnknownSire           = &ValidationError{Fields: []problem.FieldError{{Field: "sire_id", Code: "unknown", Message: "does not reference an existing animal"}}}
+	ErrUnknownDam            = &ValidationError{Fields: []problem.FieldError{{Field: "dam_id", Code: "unknown", Message: "does not reference an existing animal"}}}
+	ErrSameParents           = &ValidationError{Fields: []problem.FieldError{{Field: "dam_id", Code: "invalid", Message: "must not be the sire as well"}}}
+	ErrDueBeforeAdministered = &ValidationError{Fields: []problem.FieldError{{Field: "due_on", Code: "invalid", Message: "must not be before administered_on"}}}
+	ErrUnknownOwner          = &ValidationError{Fields: []problem.FieldError{{Field: "owner_id", Code: "unknown", Message: "does not reference an existing owner"}}}
+	ErrUnknownEnclosure      = &ValidationError{Fields: []problem.FieldError{{Field: "enclosure_id", Code: "unknown", Message: "does not reference an existing enclosure"}}}
+	ErrReturnBeforeAdoption  = &ValidationError{Fields: []problem.FieldError{{Field: "returned_on", Code: "invalid", Message: "must not be before adopted_on"}}}
+)
+
+// errorStatuses maps each error kind to its HTTP status code and problem type.
+var errorStatuses = []struct {
+	kind        error
+	status      int
+	problemType string
+}{
+	{ErrNotFound, http.StatusNotFound, problem.TypeNotFound},
+	{ErrValidation, http.StatusUnprocessableEntity, problem.TypeValidation},
+	{ErrConflict, http.StatusConflict, problem.TypeConflict},
+	{ErrBadRequest, http.StatusBadRequest, problem.TypeBadRequest},
+	{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, problem.TypeUnsupportedMediaType},
+	{ErrNotAcceptable, http.StatusNotAcceptable, problem.TypeNotAcceptable},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed, problem.TypePreconditionFailed},
+	{ErrPreconditionRequired, http.StatusPreconditionRequired, problem.TypePreconditionRequired},
+	{ErrPayloadTooLarge, http.StatusRequestEntityTooLarge, problem.TypePayloadTooLarge},
+}
+
+// StatusForError returns the HTTP status code for err.
+// Errors that do not wrap a known kind are internal server errors.
+func StatusForError(err error) int {
+	return ProblemForError(err).Status
+}
+
+// ProblemForError translates err into problem details. The message of domain
+// errors becomes the detail, anything else gets a generic internal error.
+func ProblemForError(err error) problem.Problem {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.kind) {
+			p := problem.New(e.problemType, e.status, err.Error())
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				p.Detail = "the request has invalid fields"
+				p.Errors = verr.Fields
+			}
+			return p
+		}
+	}
+	return problem.New(problem.TypeInternal, http.StatusInternalServerError, "internal server error")
+}
+
+// badRequest builds an ErrBadRequest error with a client facing message.
+func badRequest(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrBadRequest, fmt.Sprintf(format, args...))
+}
+
+// writeProblem writes err as a problem+json response. Errors that are not
+// domain errors are logged, since their message is not passed to the client.
+func writeProblem(ctx *gin.Context, module Module, err error) {
+	p := ProblemForError(err)
+	if p.Status == http.StatusInternalServerError {
+		module.NewTransactionLogger(ctx).Error("request failed", "error", err, "path", ctx.FullPath())
+	}
+
+	problem.Write(ctx, p)
+}
+
+func (h *AnimalHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *AdoptionHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *AttachmentHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *EnclosureHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *HistoryHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *MedicalRecordHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *OwnerHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *SpeciesHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
+
+func (h *TagHandler) respondError(ctx *gin.Context, err error) {
+	writeProblem(ctx, h.module, err)
+}
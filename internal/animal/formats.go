@@ -0,0 +1,508 @@
+package animal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v3"
+)
+
+// member is one member of an object.
+type member struct {
+	name  string
+	value any
+}
+
+// object is a JSON object that keeps the order of its members.
+type object []member
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.name)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toTree turns a value into the tree of its JSON representation: object, []any,
+// string, json.Number, bool and nil. Every format is written from and read into
+// such a tree, so field names, date layouts and projections are the same in
+// all of them.
+func toTree(v any) (any, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	return readTree(dec)
+}
+
+func readTree(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		o := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{name: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return o, err
+	case json.Delim('['):
+		items := []any{}
+		for dec.More() {
+			item, err := readTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = dec.Token()
+		return items, err
+	}
+	return token, nil
+}
+
+// coerceTree converts the strings of a tree decoded from an untyped format to
+// the numbers and booleans the fields of t expect. Strings that do not parse
+// are left for the JSON binding to reject.
+func coerceTree(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case object:
+		if t.Kind() != reflect.Struct {
+			return v
+		}
+		for i, m := range v {
+			for j := range t.NumField() {
+				if name, _, _ := strings.Cut(t.Field(j).Tag.Get("json"), ","); name == m.name {
+					v[i].value = coerceTree(m.value, t.Field(j).Type)
+				}
+			}
+		}
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return v
+		}
+		for i := range v {
+			v[i] = coerceTree(v[i], t.Elem())
+		}
+	case string:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return json.Number(v)
+			}
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	}
+	return v
+}
+
+// encodeCSV writes a header row of the columns of doc and a row per record:
+// every item of the records member, or the document itself when doc has none.
+// Nested objects and arrays are written as JSON in a single cell and null as
+// an empty cell.
+func encodeCSV(w io.Writer, doc document, tree any) error {
+	records := []any{tree}
+	if doc.records != "" {
+		records = nil
+		if o, ok := tree.(object); ok {
+			for _, m := range o {
+				if items, ok := m.value.([]any); ok && m.name == doc.records {
+					records = items
+				}
+			}
+		}
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(doc.columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		o, _ := record.(object)
+		row := make([]string, len(doc.columns))
+		for _, m := range o {
+			i := slices.Index(doc.columns, m.name)
+			if i < 0 {
+				continue
+			}
+			cell, err := csvCell(m.value)
+			if err != nil {
+				return err
+			}
+			row[i] = cell
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// formulaPrefixes start cells that spreadsheets evaluate as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvCell writes v like scalarText does, with a quote in front of text that
+// spreadsheets would evaluate as a formula.
+func csvCell(v any) (string, error) {
+	cell, err := scalarText(v)
+	// a leading quote makes spreadsheets show the text as it is
+	if s, ok := v.(string); ok && s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + cell, err
+	}
+	return cell, err
+}
+
+// scalarText writes v as text: strings as they are, null as an empty string
+// and nested values as JSON.
+func scalarText(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	cell, err := json.Marshal(v)
+	return string(cell), err
+}
+
+// decodeCSV reads a header row followed by one record, or with list by any
+// number of them. Empty cells are left out.
+func decodeCSV(body []byte, list bool) (any, error) {
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 || (!list && len(rows) != 2) {
+		if list {
+			return nil, errors.New("expected a header row and at least one record")
+		}
+		return nil, errors.New("expected a header row and exactly one record")
+	}
+
+	records := make([]any, len(rows)-1)
+	for i, row := range rows[1:] {
+		o := object{}
+		for j, cell := range row {
+			if cell != "" {
+				o = append(o, member{name: rows[0][j], value: unescapeCSVCell(cell)})
+			}
+		}
+		records[i] = o
+	}
+	if !list {
+		return records[0], nil
+	}
+	return records, nil
+}
+
+// unescapeCSVCell drops the quote csvCell puts before formula characters.
+func unescapeCSVCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func encodeYAML(w io.Writer, _ document, tree any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(tree)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func yamlNode(v any) *yaml.Node {
+	switch v := v.(type) {
+	case object:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, m := range v {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: m.name}, yamlNode(m.value))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.String()}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+func decodeYAML(body []byte, _ bool) (any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+	return yamlTree(doc.Content[0])
+}
+
+func yamlTree(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		o := object{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := yamlTree(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{name: node.Content[i].Value, value: value})
+		}
+		return o, nil
+	case yaml.SequenceNode:
+		items := make([]any, len(node.Content))
+		for i, item := range node.Content {
+			value, err := yamlTree(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = value
+		}
+		return items, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool":
+			var b bool
+			err := node.Decode(&b)
+			return b, err
+		case "!!int", "!!float":
+			return json.Number(node.Value), nil
+		}
+		// timestamps such as an unquoted birth_date stay as written
+		return node.Value, nil
+	}
+	return nil, fmt.Errorf("unsupported YAML node at line %d", node.Line)
+}
+
+// nilAttr marks an element whose value is null.
+var nilAttr = xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"}
+
+// encodeXML writes the document as an element named after doc.root. Members
+// become child elements, the items of an array item elements.
+func encodeXML(w io.Writer, doc document, tree any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := writeXML(enc, doc.root, tree); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if v == nil {
+		start.Attr = []xml.Attr{nilAttr}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case object:
+		for _, m := range v {
+			if err := writeXML(enc, m.name, m.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		text, err := scalarText(v)
+		if err != nil {
+			return err
+		}
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlElement is an element as read by decodeXML.
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Text     string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// decodeXML reads the layout encodeXML writes. The name of the root element
+// does not matter; an element with only item children is an array.
+func decodeXML(body []byte, _ bool) (any, error) {
+	var root xmlElement
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, err
+	}
+	return xmlTree(root), nil
+}
+
+func xmlTree(e xmlElement) any {
+	if slices.Contains(e.Attrs, nilAttr) {
+		return nil
+	}
+	if len(e.Children) == 0 {
+		return e.Text
+	}
+	if !slices.ContainsFunc(e.Children, func(c xmlElement) bool { return c.XMLName.Local != "item" }) {
+		items := make([]any, len(e.Children))
+		for i, c := range e.Children {
+			items[i] = xmlTree(c)
+		}
+		return items
+	}
+	o := object{}
+	for _, c := range e.Children {
+		o = append(o, member{name: c.XMLName.Local, value: xmlTree(c)})
+	}
+	return o
+}
+
+// msgpackMap encodes an object as a MessagePack map in the order of its members.
+type msgpackMap []any
+
+func (msgpackMap) MapBySlice() {}
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.WriteExt = true
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return h
+}
+
+func encodeMsgpack(w io.Writer, _ document, tree any) error {
+	return codec.NewEncoder(w, newMsgpackHandle()).Encode(msgpackValue(tree))
+}
+
+func msgpackValue(v any) any {
+	switch v := v.(type) {
+	case object:
+		m := make(msgpackMap, 0, 2*len(v))
+		for _, member := range v {
+			m = append(m, member.name, msgpackValue(member.value))
+		}
+		return m
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = msgpackValue(item)
+		}
+		return items
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+func decodeMsgpack(body []byte, _ bool) (any, error) {
+	var v any
+	if err := codec.NewDecoderBytes(body, newMsgpackHandle()).Decode(&v); err != nil {
+		return nil, err
+	}
+	return msgpackTree(v), nil
+}
+
+// msgpackTree turns a decoded MessagePack value into a tree. Maps have no
+// order in Go, so members come in alphabetical order.
+func msgpackTree(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		o := object{}
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			o = append(o, member{name: name, value: msgpackTree(v[name])})
+		}
+		return o
+	case []any:
+		for i := range v {
+			v[i] = msgpackTree(v[i])
+		}
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return v
+}
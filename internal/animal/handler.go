@@ -1,8 +1,11 @@
 package animal
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,88 +21,278 @@ func NewAnimalHandler(module Module, repo AnimalRepository) *AnimalHandler {
 
 func (h *AnimalHandler) CreateAnimalHandler(ctx *gin.Context) {
 	var req AnimalCreateRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Input"})
+	if err := bindBody(ctx, &req); err != nil {
+		h.respondError(ctx, err)
 		return
 	}
 
-	err := h.repo.CreateAnimal(req)
+	animal, err := h.repo.CreateAnimal(req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create animal"})
+		h.respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Animal created successfully"})
+	ctx.Header("Location", fmt.Sprintf("/animals/%d", animal.ID))
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusCreated, animalDocument(Projection{}), animal)
 }
 
 func (h *AnimalHandler) UpdateAnimalHandler(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	idInt, errA := strconv.Atoi(idStr)
-	if errA != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
 		return
 	}
-	id := int64(idInt)
 
 	var req AnimalUpdateRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Input"})
+	if err := bindBody(ctx, &req); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	animal, err := h.repo.UpdateAnimal(id, version, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusOK, animalDocument(Projection{}), animal)
+}
+
+func (h *AnimalHandler) PatchAnimalHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		h.respondError(ctx, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	var patch AnimalPatch
+	switch ctx.ContentType() {
+	case MergePatchContentType, gin.MIMEJSON:
+		patch, err = decodeMergePatch(body)
+	case JSONPatchContentType:
+		patch, err = decodeJSONPatch(body)
+	default:
+		ctx.Header("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		err = fmt.Errorf("%w: %q is not a supported patch format", ErrUnsupportedMediaType, ctx.ContentType())
+	}
+	if err == nil {
+		err = validatePatch(patch)
+	}
+	if err != nil {
+		h.respondError(ctx, err)
 		return
 	}
 
-	err := h.repo.UpdateAnimal(id, req)
+	animal, err := h.repo.PatchAnimal(id, version, patch)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to updating animal"})
+		h.respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Animal updated successfully"})
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusOK, animalDocument(Projection{}), animal)
 }
 
 func (h *AnimalHandler) ListAnimalsHandler(ctx *gin.Context) {
-	var animals, err = h.repo.ListAnimals()
+	h.listAnimals(ctx, false)
+}
+
+// AdminListAnimalsHandler lists animals like ListAnimalsHandler, and can include soft deleted ones.
+func (h *AnimalHandler) AdminListAnimalsHandler(ctx *gin.Context) {
+	h.listAnimals(ctx, true)
+}
 
+func (h *AnimalHandler) listAnimals(ctx *gin.Context, admin bool) {
+	opts, err := parseListOptions(ctx, admin)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed retrieving list of animals"})
+		h.respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, animals)
+	page, err := h.repo.ListAnimals(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
 
+	writeAnimalPage(ctx, h.module, h.repo, opts, page)
+}
+
+// writeAnimalPage sends a page of animals with its next cursor, Link header and
+// validators. Projected pages include related resources that can change without
+// the animals changing, so they are sent without validators.
+func writeAnimalPage(ctx *gin.Context, module Module, repo AnimalRepository, opts ListOptions, page AnimalPage) {
+	var nextCursor *string
+	if page.HasMore {
+		last := page.Animals[len(page.Animals)-1]
+		token := EncodeCursor(cursorAfter(last, opts.Sort))
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, opts.Limit, nextCursor))
+	if len(opts.Projection.Include) == 0 && writeValidators(ctx, listETag(page, nextCursor), lastModified(page.Animals...)) {
+		return
+	}
+	if opts.Projection.empty() {
+		render(ctx, module, http.StatusOK, animalListDocument(opts.Projection), AnimalListResponse{Data: page.Animals, NextCursor: nextCursor})
+		return
+	}
+
+	animals, err := projectAnimals(repo, opts.Projection, page.Animals)
+	if err != nil {
+		writeProblem(ctx, module, err)
+		return
+	}
+	render(ctx, module, http.StatusOK, animalListDocument(opts.Projection), projectedAnimalList{Data: animals, NextCursor: nextCursor})
 }
 
 func (h *AnimalHandler) GetAnimalHandler(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	idInt, errA := strconv.Atoi(idStr)
-	if errA != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	query := ctx.Request.URL.Query()
+	asOf, err := parseAsOf(query)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	projection, err := parseProjection(query)
+	if err != nil {
+		h.respondError(ctx, err)
 		return
 	}
-	id := int64(idInt)
 
-	var animal, err = h.repo.GetAnimal(id)
+	var animal Animal
+	switch {
+	case projection.Fields != nil:
+		animal, err = h.repo.GetAnimalProjected(id, asOf, projection)
+	case asOf != nil:
+		animal, err = h.repo.GetAnimalAsOf(id, *asOf)
+	default:
+		animal, err = h.repo.GetAnimal(id)
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting animal"})
+		h.respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, animal)
+	// like on lists, included resources leave the response without validators
+	if len(projection.Include) == 0 && writeValidators(ctx, animalETag(ctx, animal, projection), animal.UpdatedAt) {
+		return
+	}
+	if projection.empty() {
+		render(ctx, h.module, http.StatusOK, animalDocument(projection), animal)
+		return
+	}
+
+	projected, err := projectAnimals(h.repo, projection, []Animal{animal})
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	render(ctx, h.module, http.StatusOK, animalDocument(projection), projected[0])
 }
 
 func (h *AnimalHandler) DeleteAnimalHandler(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	idInt, errA := strconv.Atoi(idStr)
-	if errA != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
 		return
 	}
-	id := int64(idInt)
 
-	err := h.repo.DeleteAnimal(id)
+	version, err := ifMatchVersion(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting animal"})
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteAnimal(id, version); err != nil {
+		h.respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Animal deleted successfully"})
 }
+
+func (h *AnimalHandler) RestoreAnimalHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	animal, err := h.repo.RestoreAnimal(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusOK, animalDocument(Projection{}), animal)
+}
+
+func (h *AnimalHandler) PurgeAnimalHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.PurgeAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Animal purged successfully"})
+}
+
+// PurgeDeletedAnimalsHandler permanently removes the animals that were soft
+// deleted more than older_than_days days ago.
+func (h *AnimalHandler) PurgeDeletedAnimalsHandler(ctx *gin.Context) {
+	days, err := strconv.Atoi(ctx.Query("older_than_days"))
+	if err != nil || days < 0 {
+		h.respondError(ctx, badRequest("older_than_days must be a non-negative integer"))
+		return
+	}
+
+	purged, err := h.repo.PurgeDeletedAnimals(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	h.module.NewTransactionLogger(ctx).Info("purged deleted animals", "count", purged, "older_than_days", days)
+	ctx.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// parseID reads the numeric id path parameter.
+func parseID(ctx *gin.Context) (int64, error) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidID
+	}
+	return id, nil
+}
@@ -1,20 +1,37 @@
 package animal_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/diegotremper/go-animals/infrastructure"
 	"github.com/diegotremper/go-animals/internal/animal"
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/diegotremper/go-animals/internal/idempotency"
+	"github.com/diegotremper/go-animals/internal/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v3"
 )
 
+var mockTime = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
 type mockModule struct{}
 
 func (m mockModule) RootLogger() *slog.Logger {
@@ -29,48 +46,185 @@ func (m mockModule) Db() *sqlx.DB {
 func (m mockModule) RouterGroup() *gin.RouterGroup {
 	return nil
 }
+func (m mockModule) AdminRouterGroup() *gin.RouterGroup {
+	return nil
+}
+func (m mockModule) IdempotencyStore() idempotency.Store {
+	return nil
+}
+func (m mockModule) BlobStore() blob.Store {
+	return nil
+}
 
 type mockRepo struct{}
 
-func (m mockRepo) ListAnimals() ([]animal.Animal, error) {
-	return []animal.Animal{
-		{ID: 1, Name: "Cat", Age: 3, Description: "Domestic"},
-		{ID: 2, Name: "Dog", Age: 5, Description: "Friendly"},
-	}, nil
+func (m mockRepo) ListAnimals(opts animal.ListOptions) (animal.AnimalPage, error) {
+	animals := []animal.Animal{
+		{ID: 1, Name: "Cat", Age: 3, Description: "Domestic", Version: 1, UpdatedAt: mockTime},
+		{ID: 2, Name: "Dog", Age: 5, Description: "Friendly", Version: 2, UpdatedAt: mockTime.Add(time.Hour)},
+	}
+	if opts.After != nil {
+		animals = animals[opts.After.ID:]
+	}
+	if len(animals) > opts.Limit {
+		return animal.AnimalPage{Animals: animals[:opts.Limit], HasMore: true}, nil
+	}
+	return animal.AnimalPage{Animals: animals}, nil
 }
 
-func (m mockRepo) CreateAnimal(r animal.AnimalCreateRequest) error           { return nil }
-func (m mockRepo) UpdateAnimal(id int64, r animal.AnimalUpdateRequest) error { return nil }
-func (m mockRepo) GetAnimal(id int64) (animal.Animal, error) {
+// born mimics how the repository dates the birth of an animal, with today
+// being mockTime.
+func born(age *int, birthDate *string, estimated bool) (int, animal.Date, bool) {
+	if birthDate != nil {
+		d, _ := animal.ParseDate(*birthDate)
+		years := mockTime.Year() - d.Year()
+		if mockTime.YearDay() < d.YearDay() {
+			years--
+		}
+		return years, d, estimated
+	}
+	var years int
+	if age != nil {
+		years = *age
+	}
+	return years, animal.Date{Time: time.Date(mockTime.Year()-years, mockTime.Month(), mockTime.Day(), 0, 0, 0, 0, time.UTC)}, true
+}
+
+func (m mockRepo) CreateAnimal(r animal.AnimalCreateRequest) (animal.Animal, error) {
+	a := animal.Animal{ID: 3, Name: r.Name, Description: r.Description, Status: animal.StatusIntake, Version: 1, CreatedAt: mockTime, UpdatedAt: mockTime}
+	a.Age, a.BirthDate, a.BirthDateEstimated = born(r.Age, r.BirthDate, r.BirthDateEstimated)
+	return a, nil
+}
+func (m mockRepo) CreateAnimals(reqs []animal.AnimalCreateRequest) ([]animal.Animal, error) {
+	animals := make([]animal.Animal, len(reqs))
+	for i, r := range reqs {
+		animals[i] = animal.Animal{ID: int64(10 + i), Name: r.Name, Description: r.Description, Version: 1}
+		animals[i].Age, animals[i].BirthDate, animals[i].BirthDateEstimated = born(r.Age, r.BirthDate, r.BirthDateEstimated)
+	}
+	return animals, nil
+}
+func (m mockRepo) CreateEachAnimal(reqs []animal.AnimalCreateRequest) ([]animal.Animal, []error, error) {
+	animals, _ := m.CreateAnimals(reqs)
+	errs := make([]error, len(reqs))
+	for i, r := range reqs {
+		if r.SpeciesID != nil && *r.SpeciesID == 99 {
+			animals[i], errs[i] = animal.Animal{}, animal.ErrUnknownSpecies
+		}
+	}
+	return animals, errs, nil
+}
+func (m mockRepo) UpdateAnimal(id int64, version int64, r animal.AnimalUpdateRequest) (animal.Animal, error) {
+	a := animal.Animal{ID: id, Name: r.Name, Description: r.Description, Status: animal.StatusAvailable, Version: version + 1, CreatedAt: mockTime, UpdatedAt: mockTime}
+	a.Age, a.BirthDate, a.BirthDateEstimated = born(r.Age, r.BirthDate, r.BirthDateEstimated)
+	return a, nil
+}
+func (m mockRepo) PatchAnimal(id int64, version int64, p animal.AnimalPatch) (animal.Animal, error) {
 	return animal.Animal{ID: id, Name: "Lion", Age: 7, Description: "Fierce"}, nil
 }
-func (m mockRepo) DeleteAnimal(id int64) error { return nil }
+func (m mockRepo) GetAnimal(id int64) (animal.Animal, error) {
+	return animal.Animal{ID: id, Name: "Lion", Age: 7, Description: "Fierce", Version: 4, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockRepo) GetAnimalAsOf(id int64, asOf time.Time) (animal.Animal, error) {
+	if asOf.Before(mockTime) {
+		return animal.Animal{}, fmt.Errorf("%w: id=%d", animal.ErrAnimalNotFound, id)
+	}
+	return animal.Animal{ID: id, Name: "Cub", Age: 1, Description: "Young", Version: 1, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockRepo) GetAnimalProjected(id int64, asOf *time.Time, p animal.Projection) (animal.Animal, error) {
+	if asOf != nil {
+		return m.GetAnimalAsOf(id, *asOf)
+	}
+	return m.GetAnimal(id)
+}
+func (m mockRepo) Related(animals []animal.Animal, include []string) (animal.RelatedResources, error) {
+	return animal.RelatedResources{}, nil
+}
+func (m mockRepo) DeleteAnimal(id int64, version int64) error { return nil }
+func (m mockRepo) RestoreAnimal(id int64) (animal.Animal, error) {
+	return animal.Animal{ID: id, Name: "Lion", Age: 7, Description: "Fierce", Version: 6, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockRepo) PurgeAnimal(id int64) error { return nil }
+func (m mockRepo) PurgeDeletedAnimals(deletedBefore time.Time) (int64, error) {
+	return 2, nil
+}
+func (m mockRepo) Ancestors(id int64, depth int) (animal.PedigreeGraph, error) {
+	return animal.PedigreeGraph{RootID: id, Depth: depth, Nodes: []animal.PedigreeNode{{Animal: animal.Animal{ID: id}}}, Edges: []animal.PedigreeEdge{}}, nil
+}
+func (m mockRepo) Descendants(id int64, depth int) (animal.PedigreeGraph, error) {
+	return m.Ancestors(id, depth)
+}
 
-func (m mockRepo) CreateAnimalFail(r animal.AnimalCreateRequest) error {
-	return errors.New("failed to create")
+// TransitionAnimal moves the animal on from available. Only animal 1 has been
+// adopted.
+func (m mockRepo) TransitionAnimal(id int64, version int64, req animal.StatusTransitionRequest) (animal.Animal, error) {
+	if !animal.CanTransition(animal.StatusAvailable, req.To) {
+		return animal.Animal{}, fmt.Errorf("%w: animal %d cannot go from available to %s", animal.ErrIllegalTransition, id, req.To)
+	}
+	if req.To == animal.StatusAdopted && id != 1 {
+		return animal.Animal{}, fmt.Errorf("%w: id=%d", animal.ErrNoActiveAdoption, id)
+	}
+	return animal.Animal{ID: id, Name: "Lion", Age: 7, Description: "Fierce", Status: req.To, Version: 5, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
 }
-func (m mockRepo) UpdateAnimalFail(id int64, r animal.AnimalUpdateRequest) error {
-	return errors.New("failed to update")
+func (m mockRepo) StatusHistory(id int64) ([]animal.StatusChange, error) {
+	return []animal.StatusChange{{ID: 1, AnimalID: id, From: animal.StatusIntake, To: animal.StatusAvailable, Reason: "healthy", ChangedAt: mockTime}}, nil
+}
+
+// MoveAnimal has room in enclosure 2 only; enclosure 3 is full.
+func (m mockRepo) MoveAnimal(id int64, version int64, req animal.MoveRequest) (animal.Animal, error) {
+	if req.EnclosureID != nil {
+		switch *req.EnclosureID {
+		case 2:
+		case 3:
+			return animal.Animal{}, fmt.Errorf("%w: enclosure 3 houses 4 of 4 animals", animal.ErrEnclosureFull)
+		default:
+			return animal.Animal{}, animal.ErrUnknownEnclosure
+		}
+	}
+	return animal.Animal{ID: id, Name: "Lion", Age: 7, Description: "Fierce", Status: animal.StatusAvailable, EnclosureID: req.EnclosureID, Version: 5, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+
+func (m mockRepo) CreateAnimalFail(r animal.AnimalCreateRequest) (animal.Animal, error) {
+	return animal.Animal{}, errors.New("failed to create")
+}
+func (m mockRepo) UpdateAnimalFail(id int64, r animal.AnimalUpdateRequest) (animal.Animal, error) {
+	return animal.Animal{}, errors.New("failed to update")
 }
 func (m mockRepo) GetAnimalFail(id int64) (animal.Animal, error) {
 	return animal.Animal{}, errors.New("not found")
 }
+func (m mockRepo) PatchAnimalFail(id int64, p animal.AnimalPatch) (animal.Animal, error) {
+	return animal.Animal{}, errors.New("failed to patch")
+}
 func (m mockRepo) DeleteAnimalFail(id int64) error { return errors.New("failed to delete") }
 
+type notFoundRepo struct {
+	mockRepo
+}
+
+func (m notFoundRepo) GetAnimal(id int64) (animal.Animal, error) {
+	return animal.Animal{}, fmt.Errorf("%w: id=%d", animal.ErrAnimalNotFound, id)
+}
+func (m notFoundRepo) RestoreAnimal(id int64) (animal.Animal, error) {
+	return animal.Animal{}, fmt.Errorf("%w: id=%d", animal.ErrAnimalNotDeleted, id)
+}
+
 type mockFailRepo struct {
 	mockRepo
 }
 
-func (m mockFailRepo) CreateAnimal(r animal.AnimalCreateRequest) error {
+func (m mockFailRepo) CreateAnimal(r animal.AnimalCreateRequest) (animal.Animal, error) {
 	return m.CreateAnimalFail(r)
 }
-func (m mockFailRepo) UpdateAnimal(id int64, r animal.AnimalUpdateRequest) error {
+func (m mockFailRepo) UpdateAnimal(id int64, version int64, r animal.AnimalUpdateRequest) (animal.Animal, error) {
 	return m.UpdateAnimalFail(id, r)
 }
+func (m mockFailRepo) PatchAnimal(id int64, version int64, p animal.AnimalPatch) (animal.Animal, error) {
+	return m.PatchAnimalFail(id, p)
+}
 func (m mockFailRepo) GetAnimal(id int64) (animal.Animal, error) {
 	return m.GetAnimalFail(id)
 }
-func (m mockFailRepo) DeleteAnimal(id int64) error {
+func (m mockFailRepo) DeleteAnimal(id int64, version int64) error {
 	return m.DeleteAnimalFail(id)
 }
 
@@ -81,12 +235,169 @@ func TestListAnimalsHandler(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals", nil)
 
 	handler.ListAnimalsHandler(ctx)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Cat")
 	assert.Contains(t, w.Body.String(), "Dog")
+	assert.Contains(t, w.Body.String(), `"next_cursor":null`)
+	assert.NotContains(t, w.Header().Get("Link"), `rel="next"`)
+}
+
+func TestListAnimalsHandler_Pagination(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?limit=1", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	var body animal.AnimalListResponse
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, "Cat", body.Data[0].Name)
+	if assert.NotNil(t, body.NextCursor) {
+		cursor, err := animal.DecodeCursor(*body.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), cursor.ID)
+		assert.Contains(t, w.Header().Get("Link"), "cursor="+*body.NextCursor)
+		assert.Contains(t, w.Header().Get("Link"), `rel="next"`)
+	}
+
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?limit=1&cursor="+*body.NextCursor, nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Dog")
+	assert.Contains(t, w.Body.String(), `"next_cursor":null`)
+}
+
+func TestListAnimalsHandler_InvalidParams(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	for _, target := range []string{"/animals?limit=0", "/animals?limit=1000", "/animals?cursor=not-a-cursor"} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+
+		handler.ListAnimalsHandler(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+}
+
+type recordingRepo struct {
+	mockRepo
+	opts    *animal.ListOptions
+	patch   *animal.AnimalPatch
+	version *int64
+	err     error
+}
+
+func (m recordingRepo) DeleteAnimal(id int64, version int64) error {
+	*m.version = version
+	return m.err
+}
+
+func (m recordingRepo) PatchAnimal(id int64, version int64, p animal.AnimalPatch) (animal.Animal, error) {
+	*m.patch = p
+	if m.err != nil {
+		return animal.Animal{}, m.err
+	}
+	return m.mockRepo.PatchAnimal(id, version, p)
+}
+
+func (m recordingRepo) ListAnimals(opts animal.ListOptions) (animal.AnimalPage, error) {
+	*m.opts = opts
+	return m.mockRepo.ListAnimals(opts)
+}
+
+func TestListAnimalsHandler_FiltersAndSort(t *testing.T) {
+	module := mockModule{}
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?name_prefix=Ca&age_gte=2&age_lte=9&description_contains=dom&updated_since=2025-01-02T03:04:05Z&sort=-age,name", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &animal.StringFilter{Match: animal.MatchPrefix, Value: "Ca"}, repo.opts.Name)
+	assert.Equal(t, 2, *repo.opts.AgeGTE)
+	assert.Equal(t, 9, *repo.opts.AgeLTE)
+	assert.Equal(t, "dom", *repo.opts.DescriptionContains)
+	assert.True(t, mockTime.Equal(*repo.opts.UpdatedSince))
+	assert.Equal(t, []animal.SortField{{Field: "age", Desc: true}, {Field: "name"}}, repo.opts.Sort)
+}
+
+func TestListAnimalsHandler_SortByAgeCursor(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	list := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+		handler.ListAnimalsHandler(ctx)
+		return w
+	}
+
+	w := list("/animals?sort=age&limit=1")
+
+	var page animal.AnimalListResponse
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page)) && assert.NotNil(t, page.NextCursor) {
+		cursor, err := animal.DecodeCursor(*page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"0001-01-01"}, cursor.Values)
+
+		assert.Equal(t, http.StatusOK, list("/animals?sort=age&limit=1&cursor="+*page.NextCursor).Code)
+	}
+}
+
+func TestListAnimalsHandler_InvalidFilters(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	cases := map[string]string{
+		"/animals?color=black":                                           `unknown query parameter \"color\"`,
+		"/animals?name_suffix=at":                                        `unsupported operator \"suffix\" for field \"name\"`,
+		"/animals?age=3":                                                 `field \"age\" cannot be filtered without an operator`,
+		"/animals?age_gte=old":                                           "age_gte must be an integer",
+		"/animals?age_gte=9&age_lte=2":                                   "age_gte must not be greater than age_lte",
+		"/animals?name=Cat&name_prefix=C":                                "only one of name, name_prefix and name_contains may be given",
+		"/animals?updated_since=yesterday":                               "updated_since must be an RFC 3339 timestamp",
+		"/animals?sort=weight":                                           `cannot sort by \"weight\"`,
+		"/animals?sort=name,-name":                                       `sort field \"name\" given more than once`,
+		"/animals?sort=name&cursor=eyJpZCI6MX0":                          "cursor was issued for a different sort order",
+		"/animals?sort=age&cursor=eyJpZCI6MSwicyI6ImFnZSIsInYiOlszXX0":   "cursor does not hold a birth date to sort by age",
+		"/animals?sort=name&cursor=eyJpZCI6MSwicyI6Im5hbWUiLCJ2IjpbM119": "cursor does not hold a string to sort by name",
+		"/animals?sort=updated_at&cursor=eyJpZCI6MSwicyI6InVwZGF0ZWRfYXQiLCJ2IjpbInNvb24iXX0": "cursor does not hold a timestamp to sort by updated_at",
+		"/animals?status=sold": "status must be one of intake, quarantine, available, adopted, deceased",
+	}
+	for target, message := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+
+		handler.ListAnimalsHandler(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		assert.Contains(t, w.Body.String(), message, target)
+	}
 }
 
 func TestCreateAnimalHandler(t *testing.T) {
@@ -101,8 +412,108 @@ func TestCreateAnimalHandler(t *testing.T) {
 
 	handler.CreateAnimalHandler(ctx)
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Animal created successfully")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/animals/3", w.Header().Get("Location"))
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id":3,"name":"Tiger","age":4,"birth_date":"2021-01-02","birth_date_estimated":true,"description":"Wild","species_id":null,"sire_id":null,"dam_id":null,"status":"intake","enclosure_id":null,"version":1,"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+}
+
+func TestCreateAnimalHandler_Validation(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	body := fmt.Sprintf(`{"name":"","age":201,"description":%q}`, strings.Repeat("x", 2001))
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("POST", "/animals", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	handler.CreateAnimalHandler(ctx)
+
+	var p problem.Problem
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, problem.TypeValidation, p.Type)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "name", Code: "required", Message: "is required"},
+		{Field: "age", Code: "too_large", Message: "must be at most 200"},
+		{Field: "description", Code: "too_long", Message: "must be at most 2000 characters long"},
+	}, p.Errors)
+}
+
+func TestCreateAnimalHandler_BirthDate(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("POST", "/animals", strings.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		handler.CreateAnimalHandler(ctx)
+		return w
+	}
+
+	w := create(`{"name":"Tiger","birth_date":"2020-06-15"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"age":4,"birth_date":"2020-06-15","birth_date_estimated":false`)
+
+	w = create(`{"name":"Tiger","birth_date":"2020-06-15","birth_date_estimated":true}`)
+
+	assert.Contains(t, w.Body.String(), `"birth_date_estimated":true`)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(animal.DateLayout)
+	cases := []struct {
+		body  string
+		field problem.FieldError
+	}{
+		{`{"name":"Tiger","age":4,"birth_date":"2020-06-15"}`,
+			problem.FieldError{Field: "age", Code: "invalid", Message: "must not be given together with birth_date"}},
+		{`{"name":"Tiger","birth_date":"` + tomorrow + `"}`,
+			problem.FieldError{Field: "birth_date", Code: "invalid", Message: "must not be in the future"}},
+		{`{"name":"Tiger","birth_date":"15/06/2020"}`,
+			problem.FieldError{Field: "birth_date", Code: "invalid", Message: "must be formatted as 2006-01-02"}},
+	}
+	for _, tc := range cases {
+		w := create(tc.body)
+
+		var p problem.Problem
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), tc.body)
+		assert.Equal(t, []problem.FieldError{tc.field}, p.Errors, tc.body)
+	}
+}
+
+func TestCreateAnimalHandler_InvalidInput(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	cases := []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"name":"Tiger","age":"four"}`, http.StatusUnprocessableEntity, "invalid_type"},
+		{`{"name":"Tiger","age":-1}`, http.StatusUnprocessableEntity, "too_small"},
+		{`{"name":`, http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("POST", "/animals", strings.NewReader(tc.body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateAnimalHandler(ctx)
+
+		var p problem.Problem
+		assert.Equal(t, tc.status, w.Code, tc.body)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), tc.body)
+		if tc.code != "" && assert.Len(t, p.Errors, 1, tc.body) {
+			assert.Equal(t, "age", p.Errors[0].Field, tc.body)
+			assert.Equal(t, tc.code, p.Errors[0].Code, tc.body)
+		}
+	}
 }
 
 func TestUpdateAnimalHandler(t *testing.T) {
@@ -114,12 +525,117 @@ func TestUpdateAnimalHandler(t *testing.T) {
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 	ctx.Request = httptest.NewRequest("PUT", "/animals/1", strings.NewReader(`{"name":"Panther","age":6,"description":"Stealthy"}`))
+	ctx.Request.Header.Set("If-Match", "*")
 	ctx.Request.Header.Set("Content-Type", "application/json")
 
 	handler.UpdateAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Animal updated successfully")
+	assert.JSONEq(t, `{"id":1,"name":"Panther","age":6,"birth_date":"2019-01-02","birth_date_estimated":true,"description":"Stealthy","species_id":null,"sire_id":null,"dam_id":null,"status":"available","enclosure_id":null,"version":1,"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+}
+
+func patchAnimal(handler *animal.AnimalHandler, contentType, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("PATCH", "/animals/1", strings.NewReader(body))
+	ctx.Request.Header.Set("If-Match", "*")
+	ctx.Request.Header.Set("Content-Type", contentType)
+
+	handler.PatchAnimalHandler(ctx)
+	return w
+}
+
+func TestPatchAnimalHandler_MergePatch(t *testing.T) {
+	repo := recordingRepo{patch: &animal.AnimalPatch{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"age":6,"description":null}`)
+
+	age, description := 6, ""
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Lion"`)
+	assert.Equal(t, animal.AnimalFields{Age: &age, Description: &description}, repo.patch.Set)
+	assert.Nil(t, repo.patch.Set.Name)
+}
+
+func TestPatchAnimalHandler_BirthDate(t *testing.T) {
+	repo := recordingRepo{patch: &animal.AnimalPatch{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"birth_date":"2020-06-15"}`)
+
+	birthDate := "2020-06-15"
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{BirthDate: &birthDate}, repo.patch.Set)
+
+	// removing the birth date makes the animal a newborn, like omitting it on create
+	w = patchAnimal(handler, "application/json-patch+json", `[{"op":"remove","path":"/birth_date"}]`)
+
+	age := 0
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{Age: &age}, repo.patch.Set)
+
+	for _, body := range []string{`{"age":3,"birth_date":"2020-06-15"}`, `{"birth_date":null,"age":5}`, `{"age":null,"birth_date":"2020-06-15"}`} {
+		w = patchAnimal(handler, "application/merge-patch+json", body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		assert.Contains(t, w.Body.String(), "must not be given together with birth_date", body)
+	}
+}
+
+func TestPatchAnimalHandler_JSONPatch(t *testing.T) {
+	repo := recordingRepo{patch: &animal.AnimalPatch{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := patchAnimal(handler, "application/json-patch+json", `[
+		{"op":"test","path":"/name","value":"Cat"},
+		{"op":"replace","path":"/name","value":"Tiger"},
+		{"op":"test","path":"/name","value":"Tiger"},
+		{"op":"remove","path":"/description"}
+	]`)
+
+	name, tested, description := "Tiger", "Cat", ""
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{Name: &name, Description: &description}, repo.patch.Set)
+	assert.Equal(t, animal.AnimalFields{Name: &tested}, repo.patch.Test)
+}
+
+func TestPatchAnimalHandler_Errors(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		repoErr     error
+		status      int
+	}{
+		{"unsupported media type", "text/plain", `name=Cat`, nil, http.StatusUnsupportedMediaType},
+		{"malformed merge patch", "application/merge-patch+json", `{"name":`, nil, http.StatusBadRequest},
+		{"unknown member", "application/merge-patch+json", `{"color":"red"}`, nil, http.StatusUnprocessableEntity},
+		{"remove name", "application/merge-patch+json", `{"name":null}`, nil, http.StatusUnprocessableEntity},
+		{"wrong type", "application/merge-patch+json", `{"age":"old"}`, nil, http.StatusUnprocessableEntity},
+		{"out of range", "application/merge-patch+json", `{"age":500}`, nil, http.StatusUnprocessableEntity},
+		{"empty name", "application/json-patch+json", `[{"op":"replace","path":"/name","value":""}]`, nil, http.StatusUnprocessableEntity},
+		{"unknown op", "application/json-patch+json", `[{"op":"frobnicate","path":"/name"}]`, nil, http.StatusBadRequest},
+		{"move", "application/json-patch+json", `[{"op":"move","from":"/name","path":"/description"}]`, nil, http.StatusUnprocessableEntity},
+		{"nested path", "application/json-patch+json", `[{"op":"add","path":"/name/0","value":"x"}]`, nil, http.StatusUnprocessableEntity},
+		{"local test failure", "application/json-patch+json", `[{"op":"replace","path":"/age","value":2},{"op":"test","path":"/age","value":3}]`, nil, http.StatusConflict},
+		{"stored test failure", "application/json-patch+json", `[{"op":"test","path":"/age","value":3}]`, animal.ErrPatchTestFailed, http.StatusConflict},
+		{"negative species", "application/merge-patch+json", `{"species_id":-1}`, nil, http.StatusUnprocessableEntity},
+		{"unknown species", "application/merge-patch+json", `{"species_id":99}`, animal.ErrUnknownSpecies, http.StatusUnprocessableEntity},
+		{"not found", "application/merge-patch+json", `{"age":3}`, animal.ErrAnimalNotFound, http.StatusNotFound},
+		{"repository failure", "application/merge-patch+json", `{"age":3}`, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := recordingRepo{patch: &animal.AnimalPatch{}, err: tc.repoErr}
+			handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+			w := patchAnimal(handler, tc.contentType, tc.body)
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
 }
 
 func TestGetAnimalHandler(t *testing.T) {
@@ -130,11 +646,76 @@ func TestGetAnimalHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/1", nil)
 
 	handler.GetAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Lion")
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+}
+
+func TestGetAnimalHandler_Conditional(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"no validators", "", "", http.StatusOK},
+		{"matching etag", "If-None-Match", `"4"`, http.StatusNotModified},
+		{"matching weak etag in list", "If-None-Match", `"3", W/"4"`, http.StatusNotModified},
+		{"stale etag", "If-None-Match", `"3"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Thu, 02 Jan 2025 03:04:05 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Thu, 02 Jan 2025 03:04:04 GMT", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+			ctx.Request = httptest.NewRequest("GET", "/animals/1", nil)
+			if tc.header != "" {
+				ctx.Request.Header.Set(tc.header, tc.value)
+			}
+
+			handler.GetAnimalHandler(ctx)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+			assert.Equal(t, "Thu, 02 Jan 2025 03:04:05 GMT", w.Header().Get("Last-Modified"))
+			if tc.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestListAnimalsHandler_Conditional(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+	assert.Equal(t, "Thu, 02 Jan 2025 04:04:05 GMT", w.Header().Get("Last-Modified"))
+
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals", nil)
+	ctx.Request.Header.Set("If-None-Match", etag)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
 }
 
 func TestDeleteAnimalHandler(t *testing.T) {
@@ -145,6 +726,8 @@ func TestDeleteAnimalHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("DELETE", "/animals/1", nil)
+	ctx.Request.Header.Set("If-Match", "*")
 
 	handler.DeleteAnimalHandler(ctx)
 
@@ -165,7 +748,7 @@ func TestCreateAnimalHandler_Failure(t *testing.T) {
 	handler.CreateAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "Failed to create animal")
+	assert.Contains(t, w.Body.String(), "internal server error")
 }
 
 func TestUpdateAnimalHandler_Failure(t *testing.T) {
@@ -177,12 +760,13 @@ func TestUpdateAnimalHandler_Failure(t *testing.T) {
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 	ctx.Request = httptest.NewRequest("PUT", "/animals/1", strings.NewReader(`{"name":"Panther","age":6,"description":"Stealthy"}`))
+	ctx.Request.Header.Set("If-Match", "*")
 	ctx.Request.Header.Set("Content-Type", "application/json")
 
 	handler.UpdateAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "Failed to updating animal")
+	assert.Contains(t, w.Body.String(), "internal server error")
 }
 
 func TestGetAnimalHandler_Failure(t *testing.T) {
@@ -193,11 +777,12 @@ func TestGetAnimalHandler_Failure(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "99"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/99", nil)
 
 	handler.GetAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "Error getting animal")
+	assert.Contains(t, w.Body.String(), "internal server error")
 }
 
 func TestDeleteAnimalHandler_Failure(t *testing.T) {
@@ -208,9 +793,2035 @@ func TestDeleteAnimalHandler_Failure(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "99"}}
+	ctx.Request = httptest.NewRequest("DELETE", "/animals/99", nil)
+	ctx.Request.Header.Set("If-Match", "*")
 
 	handler.DeleteAnimalHandler(ctx)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Contains(t, w.Body.String(), "Error deleting animal")
+	assert.Contains(t, w.Body.String(), "internal server error")
+}
+
+func TestGetAnimalHandler_NotFound(t *testing.T) {
+	module := mockModule{}
+	repo := notFoundRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "99"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/99", nil)
+	ctx.Request.Header.Set("X-Request-Id", "req-42")
+
+	handler.GetAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/not-found",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "animal not found: id=99",
+		"instance": "/animals/99",
+		"request_id": "req-42"
+	}`, w.Body.String())
+}
+
+func TestDeleteAnimalHandler_InvalidID(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "abc"}}
+	ctx.Request = httptest.NewRequest("DELETE", "/animals/abc", nil)
+
+	handler.DeleteAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid id")
+}
+
+func TestStatusForError(t *testing.T) {
+	cases := map[error]int{
+		animal.ErrAnimalNotFound:                                 http.StatusNotFound,
+		fmt.Errorf("%w: id=3", animal.ErrAnimalNotFound):         http.StatusNotFound,
+		fmt.Errorf("%w: name is required", animal.ErrValidation): http.StatusUnprocessableEntity,
+		animal.ErrPatchTestFailed:                                http.StatusConflict,
+		animal.ErrInvalidCursor:                                  http.StatusBadRequest,
+		errors.New("connection refused"):                         http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		assert.Equal(t, status, animal.StatusForError(err), err.Error())
+	}
+}
+
+func TestDeleteAnimalHandler_IfMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		ifMatch  string
+		repoErr  error
+		status   int
+		expected int64
+	}{
+		{"missing", "", nil, http.StatusPreconditionRequired, 0},
+		{"any version", "*", nil, http.StatusOK, 0},
+		{"matching version", `"4"`, nil, http.StatusOK, 4},
+		{"tag of another representation", `"4.0a1b2c3d"`, nil, http.StatusOK, 4},
+		{"stale version", `"3"`, animal.ErrVersionConflict, http.StatusPreconditionFailed, 3},
+		{"weak tag", `W/"4"`, nil, http.StatusPreconditionFailed, 0},
+		{"several tags", `"3", "4"`, nil, http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var version int64
+			repo := recordingRepo{version: &version, err: tc.repoErr}
+			handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+			ctx.Request = httptest.NewRequest("DELETE", "/animals/1", nil)
+			if tc.ifMatch != "" {
+				ctx.Request.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			handler.DeleteAnimalHandler(ctx)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.expected, version)
+		})
+	}
+}
+
+func TestUpdateAnimalHandler_IfMatch(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("PUT", "/animals/1", strings.NewReader(`{"name":"Panther","age":6,"description":"Stealthy"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Request.Header.Set("If-Match", `"4"`)
+
+	handler.UpdateAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+}
+
+func TestWriteAnimalHandlers_IfMatchRequired(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+	cases := []struct {
+		method string
+		body   string
+		serve  gin.HandlerFunc
+	}{
+		{"PUT", `{"name":"Panther","age":6}`, handler.UpdateAnimalHandler},
+		{"PATCH", `{"name":"Panther"}`, handler.PatchAnimalHandler},
+		{"DELETE", "", handler.DeleteAnimalHandler},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+			ctx.Request = httptest.NewRequest(tc.method, "/animals/1", strings.NewReader(tc.body))
+			ctx.Request.Header.Set("Content-Type", "application/merge-patch+json")
+			if tc.method == "PUT" {
+				ctx.Request.Header.Set("Content-Type", "application/json")
+			}
+
+			tc.serve(ctx)
+
+			assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{
+				"type": "/problems/precondition-required",
+				"title": "Precondition Required",
+				"status": 428,
+				"detail": "precondition required: send the ETag of the animal in If-Match, or * to write unconditionally",
+				"instance": "/animals/1"
+			}`, w.Body.String())
+		})
+	}
+}
+
+func TestRestoreAnimalHandler(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals/1/restore", nil)
+
+	handler.RestoreAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"6"`, w.Header().Get("ETag"))
+	assert.NotContains(t, w.Body.String(), "deleted_at")
+}
+
+func TestRestoreAnimalHandler_NotDeleted(t *testing.T) {
+	module := mockModule{}
+	repo := notFoundRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals/1/restore", nil)
+
+	handler.RestoreAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "animal is not deleted")
+}
+
+func TestPurgeAnimalHandler(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("DELETE", "/admin/animals/1", nil)
+
+	handler.PurgeAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Animal purged successfully")
+}
+
+func TestPurgeDeletedAnimalsHandler(t *testing.T) {
+	module := mockModule{}
+	repo := mockRepo{}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	for target, status := range map[string]int{
+		"/admin/animals?older_than_days=30": http.StatusOK,
+		"/admin/animals":                    http.StatusBadRequest,
+		"/admin/animals?older_than_days=-1": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("DELETE", target, nil)
+
+		handler.PurgeDeletedAnimalsHandler(ctx)
+
+		assert.Equal(t, status, w.Code, target)
+		if status == http.StatusOK {
+			assert.JSONEq(t, `{"purged":2}`, w.Body.String())
+		}
+	}
+}
+
+func TestListAnimalsHandler_IncludeDeleted(t *testing.T) {
+	module := mockModule{}
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(module, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?include_deleted=true", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/admin/animals?include_deleted=true", nil)
+
+	handler.AdminListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, repo.opts.IncludeDeleted)
+}
+
+func batchCreate(handler *animal.AnimalHandler, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "action", Value: ":batch"}}
+	ctx.Request = httptest.NewRequest("POST", target, strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	handler.CollectionActionHandler(ctx)
+	return w
+}
+
+func TestBatchCreateAnimalsHandler_BestEffort(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := batchCreate(handler, "/animals:batch", `[
+		{"name":"Tiger","age":4},
+		{"name":"","age":300},
+		{"name":"Puma","age":2},
+		{"name":"Lynx","age":1,"species_id":99}
+	]`)
+
+	var body animal.BatchCreateResponse
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Results, 4) {
+		assert.Equal(t, http.StatusCreated, body.Results[0].Status)
+		assert.Equal(t, "Tiger", body.Results[0].Animal.Name)
+		assert.Equal(t, http.StatusUnprocessableEntity, body.Results[1].Status)
+		assert.Nil(t, body.Results[1].Animal)
+		assert.Len(t, body.Results[1].Error.Errors, 2)
+		assert.Equal(t, http.StatusCreated, body.Results[2].Status)
+		assert.Equal(t, "Puma", body.Results[2].Animal.Name)
+		assert.Equal(t, int64(11), body.Results[2].Animal.ID)
+		assert.Equal(t, http.StatusUnprocessableEntity, body.Results[3].Status)
+		assert.Nil(t, body.Results[3].Animal)
+		assert.Equal(t, "species_id", body.Results[3].Error.Errors[0].Field)
+	}
+}
+
+func TestBatchCreateAnimalsHandler_Atomic(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := batchCreate(handler, "/animals:batch?atomic=true", `[{"name":"Tiger","age":4},{"name":"Puma","age":2}]`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "Tiger")
+	assert.Contains(t, w.Body.String(), "Puma")
+
+	w = batchCreate(handler, "/animals:batch?atomic=true", `[{"name":"Tiger","age":4},{"name":"","age":2}]`)
+
+	var p problem.Problem
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, []problem.FieldError{{Field: "[1].name", Code: "required", Message: "is required"}}, p.Errors)
+}
+
+func TestBatchCreateAnimalsHandler_InvalidBody(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	for _, body := range []string{`{"name":"Tiger"}`, `[]`, `[` + strings.Repeat(`{"name":"x"},`, animal.MaxBatchSize) + `{"name":"x"}]`} {
+		w := batchCreate(handler, "/animals:batch", body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCollectionActionHandler_UnknownAction(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "action", Value: ":explode"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals:explode", nil)
+
+	handler.CollectionActionHandler(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+type mockRevisionRepo struct{}
+
+func (m mockRevisionRepo) revisions() []animal.AnimalRevision {
+	snapshot := animal.Animal{ID: 1, Name: "Cat", Age: 3, BirthDate: *date("2022-01-02"), BirthDateEstimated: true, Description: "Domestic", Status: animal.StatusAvailable, Version: 1, CreatedAt: mockTime, UpdatedAt: mockTime}
+	revisions := []animal.AnimalRevision{{AnimalID: 1, Revision: 1, Operation: animal.OpCreate, Snapshot: snapshot, ChangedAt: mockTime}}
+
+	snapshot.Age, snapshot.BirthDate, snapshot.Version, snapshot.UpdatedAt = 4, *date("2021-01-02"), 2, mockTime.Add(time.Hour)
+	revisions = append(revisions, animal.AnimalRevision{AnimalID: 1, Revision: 2, Operation: animal.OpUpdate, Snapshot: snapshot, ChangedAt: snapshot.UpdatedAt})
+
+	deletedAt := mockTime.Add(2 * time.Hour)
+	snapshot.Version, snapshot.UpdatedAt, snapshot.DeletedAt = 3, deletedAt, &deletedAt
+	return append(revisions, animal.AnimalRevision{AnimalID: 1, Revision: 3, Operation: animal.OpDelete, Snapshot: snapshot, ChangedAt: deletedAt})
+}
+
+func (m mockRevisionRepo) ListRevisions(animalID int64, afterRevision int64, limit int) (animal.RevisionPage, error) {
+	if animalID != 1 {
+		return animal.RevisionPage{}, animal.ErrAnimalNotFound
+	}
+	revisions := m.revisions()[afterRevision:]
+	if len(revisions) > limit {
+		return animal.RevisionPage{Revisions: revisions[:limit], HasMore: true}, nil
+	}
+	return animal.RevisionPage{Revisions: revisions}, nil
+}
+
+func (m mockRevisionRepo) GetRevision(animalID int64, revision int64) (animal.AnimalRevision, error) {
+	if animalID != 1 || revision > 3 {
+		return animal.AnimalRevision{}, animal.ErrRevisionNotFound
+	}
+	return m.revisions()[revision-1], nil
+}
+
+// serve sends a request with a JSON body through router.
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// statusCase is a request sent through serve and the status it should get.
+type statusCase struct {
+	method, path, body string
+	status             int
+}
+
+func assertStatuses(t *testing.T, router *gin.Engine, cases []statusCase) {
+	for _, c := range cases {
+		assert.Equal(t, c.status, serve(router, c.method, c.path, c.body).Code, c.method+" "+c.path)
+	}
+}
+
+func historyRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewHistoryHandler(mockModule{}, mockRevisionRepo{})
+	r.GET("/animals/:id/history", handler.ListHistoryHandler)
+	r.GET("/animals/:id/history/diff", handler.DiffHistoryHandler)
+	r.GET("/animals/:id/history/:rev", handler.GetRevisionHandler)
+	return r
+}
+
+func TestListHistoryHandler(t *testing.T) {
+	w := serve(historyRouter(), "GET", "/animals/1/history?limit=2", "")
+
+	var page animal.RevisionListResponse
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Data, 2) && assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, animal.OpCreate, page.Data[0].Operation)
+		assert.Equal(t, 4, page.Data[1].Snapshot.Age)
+
+		w = serve(historyRouter(), "GET", "/animals/1/history?limit=2&cursor="+*page.NextCursor, "")
+
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Data, 1)
+		assert.Equal(t, animal.OpDelete, page.Data[0].Operation)
+		assert.Nil(t, page.NextCursor)
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve(historyRouter(), "GET", "/animals/2/history", "").Code)
+}
+
+func TestGetRevisionHandler(t *testing.T) {
+	w := serve(historyRouter(), "GET", "/animals/1/history/2", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"animal_id": 1, "revision": 2, "operation": "update", "changed_at": "2025-01-02T04:04:05Z",
+		"snapshot": {"id": 1, "name": "Cat", "age": 4, "birth_date": "2021-01-02", "birth_date_estimated": true, "description": "Domestic", "species_id": null, "sire_id": null, "dam_id": null, "status": "available", "enclosure_id": null, "version": 2,
+			"created_at": "2025-01-02T03:04:05Z", "updated_at": "2025-01-02T04:04:05Z"}
+	}`, w.Body.String())
+
+	assertStatuses(t, historyRouter(), []statusCase{
+		{"GET", "/animals/1/history/9", "", http.StatusNotFound},
+		{"GET", "/animals/1/history/0", "", http.StatusBadRequest},
+	})
+}
+
+func TestDiffHistoryHandler(t *testing.T) {
+	w := serve(historyRouter(), "GET", "/animals/1/history/diff?from=1&to=3", "")
+
+	var diff animal.RevisionDiff
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	fields := make([]string, len(diff.Changes))
+	for i, c := range diff.Changes {
+		fields[i] = c.Field
+	}
+	assert.Equal(t, []string{"age", "birth_date", "version", "updated_at", "deleted_at"}, fields)
+	assert.Equal(t, float64(3), diff.Changes[0].From)
+	assert.Equal(t, float64(4), diff.Changes[0].To)
+	assert.Equal(t, "2021-01-02", diff.Changes[1].To)
+	assert.Nil(t, diff.Changes[4].From)
+
+	assertStatuses(t, historyRouter(), []statusCase{
+		{"GET", "/animals/1/history/diff?from=1", "", http.StatusBadRequest},
+		{"GET", "/animals/1/history/diff?from=1&to=7", "", http.StatusNotFound},
+	})
+}
+
+func TestGetAnimalHandler_AsOf(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	cases := []struct {
+		query string
+		code  int
+		name  string
+	}{
+		{"", http.StatusOK, "Lion"},
+		{"?as_of=2025-01-02T03:04:05Z", http.StatusOK, "Cub"},
+		{"?as_of=2024-12-31T00:00:00Z", http.StatusNotFound, ""},
+		{"?as_of=yesterday", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+		ctx.Request = httptest.NewRequest("GET", "/animals/1"+tc.query, nil)
+
+		handler.GetAnimalHandler(ctx)
+
+		assert.Equal(t, tc.code, w.Code, tc.query)
+		if tc.name != "" {
+			assert.Contains(t, w.Body.String(), tc.name, tc.query)
+		}
+	}
+}
+
+func TestListAnimalsHandler_AsOf(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?as_of=2025-01-02T03:04:05Z&name=Cat", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, repo.opts.AsOf) {
+		assert.True(t, mockTime.Equal(*repo.opts.AsOf))
+	}
+	assert.Equal(t, &animal.StringFilter{Match: animal.MatchExact, Value: "Cat"}, repo.opts.Name)
+}
+
+func TestPatchAnimalHandler_Species(t *testing.T) {
+	repo := recordingRepo{patch: &animal.AnimalPatch{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"species_id":7}`)
+
+	species := int64(7)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{SpeciesID: &species}, repo.patch.Set)
+
+	w = patchAnimal(handler, "application/json-patch+json", `[{"op":"test","path":"/species_id","value":null},{"op":"remove","path":"/species_id"}]`)
+
+	none := int64(0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{SpeciesID: &none}, repo.patch.Set)
+	assert.Equal(t, animal.AnimalFields{SpeciesID: &none}, repo.patch.Test)
+}
+
+type mockSpeciesRepo struct{}
+
+var felidae = animal.Species{ID: 5, ParentID: new(int64), Rank: "family", ScientificName: "Felidae", CommonNames: []string{"cats"}, CreatedAt: mockTime, UpdatedAt: mockTime}
+
+func (m mockSpeciesRepo) CreateSpecies(r animal.SpeciesRequest) (animal.Species, error) {
+	if r.ScientificName == "Felidae" {
+		return animal.Species{}, fmt.Errorf("%w: a species named %q already exists", animal.ErrConflict, r.ScientificName)
+	}
+	return animal.Species{ID: 6, ParentID: r.ParentID, Rank: r.Rank, ScientificName: r.ScientificName, CommonNames: r.CommonNames, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockSpeciesRepo) UpdateSpecies(id int64, r animal.SpeciesRequest) (animal.Species, error) {
+	return animal.Species{ID: id, ParentID: r.ParentID, Rank: r.Rank, ScientificName: r.ScientificName, CommonNames: r.CommonNames}, nil
+}
+func (m mockSpeciesRepo) ListSpecies(opts animal.SpeciesListOptions) (animal.SpeciesPage, error) {
+	return animal.SpeciesPage{Species: []animal.Species{felidae}}, nil
+}
+func (m mockSpeciesRepo) GetSpecies(id int64) (animal.Species, error) {
+	if id != felidae.ID {
+		return animal.Species{}, fmt.Errorf("%w: id=%d", animal.ErrSpeciesNotFound, id)
+	}
+	return felidae, nil
+}
+func (m mockSpeciesRepo) Lineage(id int64) ([]animal.Species, error) {
+	return []animal.Species{{ID: 1, Rank: "kingdom", ScientificName: "Animalia"}, felidae}, nil
+}
+func (m mockSpeciesRepo) DeleteSpecies(id int64) error {
+	return fmt.Errorf("%w: id=%d", animal.ErrSpeciesInUse, id)
+}
+
+func speciesRouter(repo animal.AnimalRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewSpeciesHandler(mockModule{}, mockSpeciesRepo{}, repo)
+	r.POST("/species", handler.CreateSpeciesHandler)
+	r.GET("/species", handler.ListSpeciesHandler)
+	r.DELETE("/species/:id", handler.DeleteSpeciesHandler)
+	r.GET("/species/:id/lineage", handler.LineageHandler)
+	r.GET("/species/:id/animals", handler.ListSpeciesAnimalsHandler)
+	return r
+}
+
+func TestCreateSpeciesHandler(t *testing.T) {
+	w := serve(speciesRouter(mockRepo{}), "POST", "/species", `{"parent_id":5,"rank":"genus","scientific_name":"Panthera","common_names":["big cats"]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/species/6", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"common_names":["big cats"]`)
+
+	w = serve(speciesRouter(mockRepo{}), "POST", "/species", `{"rank":"breed","scientific_name":"","common_names":[""]}`)
+
+	var p problem.Problem
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.ElementsMatch(t, []problem.FieldError{
+		{Field: "rank", Code: "invalid", Message: "must be one of kingdom, phylum, class, order, family, genus, species"},
+		{Field: "scientific_name", Code: "required", Message: "is required"},
+		{Field: "common_names[0]", Code: "required", Message: "is required"},
+	}, p.Errors)
+
+	w = serve(speciesRouter(mockRepo{}), "POST", "/species", `{"parent_id":1,"rank":"family","scientific_name":"Felidae"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestListSpeciesHandler(t *testing.T) {
+	assertStatuses(t, speciesRouter(mockRepo{}), []statusCase{
+		{"GET", "/species?rank=family", "", http.StatusOK},
+		{"GET", "/species?rank=breed", "", http.StatusBadRequest},
+		{"GET", "/species?name=cat", "", http.StatusBadRequest},
+	})
+}
+
+func TestLineageHandler(t *testing.T) {
+	w := serve(speciesRouter(mockRepo{}), "GET", "/species/5/lineage", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Regexp(t, `Animalia.*Felidae`, w.Body.String())
+}
+
+func TestDeleteSpeciesHandler_InUse(t *testing.T) {
+	w := serve(speciesRouter(mockRepo{}), "DELETE", "/species/5", "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestListSpeciesAnimalsHandler(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+
+	w := serve(speciesRouter(repo), "GET", "/species/5/animals?age_gte=2", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, repo.opts.Taxon) {
+		assert.Equal(t, int64(5), *repo.opts.Taxon)
+	}
+	assert.Equal(t, 2, *repo.opts.AgeGTE)
+
+	assert.Equal(t, http.StatusNotFound, serve(speciesRouter(repo), "GET", "/species/9/animals", "").Code)
+}
+
+// memoryAttachmentRepo keeps attachments in memory and queues the storage keys
+// of deleted ones, like the database trigger does.
+type memoryAttachmentRepo struct {
+	attachments map[int64]animal.Attachment
+	pending     []string
+	// failed counts the failed deletions of a key, which is then left out
+	// of the pending ones as if its retry delay had not passed yet
+	failed map[string]int
+}
+
+func (m *memoryAttachmentRepo) CreateAttachment(a animal.Attachment) (animal.Attachment, error) {
+	a.ID = int64(len(m.attachments) + 1)
+	a.CreatedAt = mockTime
+	m.attachments[a.ID] = a
+	return a, nil
+}
+func (m *memoryAttachmentRepo) ListAttachments(animalID int64) ([]animal.Attachment, error) {
+	var attachments []animal.Attachment
+	for _, a := range m.attachments {
+		if a.AnimalID == animalID {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+func (m *memoryAttachmentRepo) GetAttachment(animalID, id int64) (animal.Attachment, error) {
+	a, ok := m.attachments[id]
+	if !ok || a.AnimalID != animalID {
+		return animal.Attachment{}, fmt.Errorf("%w: id=%d", animal.ErrAttachmentNotFound, id)
+	}
+	return a, nil
+}
+func (m *memoryAttachmentRepo) DeleteAttachment(animalID, id int64) error {
+	a, err := m.GetAttachment(animalID, id)
+	if err != nil {
+		return err
+	}
+	delete(m.attachments, id)
+	m.pending = append(m.pending, a.StorageKey)
+	return nil
+}
+func (m *memoryAttachmentRepo) PendingBlobDeletions(limit int) ([]string, error) {
+	keys := slices.DeleteFunc(slices.Clone(m.pending), func(k string) bool { return m.failed[k] > 0 })
+	return keys[:min(limit, len(keys))], nil
+}
+func (m *memoryAttachmentRepo) BlobDeleted(key string) error {
+	m.pending = slices.DeleteFunc(m.pending, func(k string) bool { return k == key })
+	return nil
+}
+func (m *memoryAttachmentRepo) BlobDeleteFailed(key string) error {
+	if m.failed == nil {
+		m.failed = map[string]int{}
+	}
+	m.failed[key]++
+	return nil
+}
+
+// pngHeader is the start of a PNG file, enough for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func multipartFile(t *testing.T, field, filename string, content []byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile(field, filename)
+	assert.NoError(t, err)
+	part.Write(content)
+	assert.NoError(t, w.Close())
+	return &body, w.FormDataContentType()
+}
+
+func serveAttachments(repo animal.AnimalRepository, attachments animal.AttachmentRepository, blobs blob.Store, req *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewAttachmentHandler(mockModule{}, attachments, repo, blobs)
+	r.POST("/animals/:id/attachments", handler.UploadAttachmentHandler)
+	r.GET("/animals/:id/attachments", handler.ListAttachmentsHandler)
+	r.GET("/animals/:id/attachments/:attachment_id", handler.DownloadAttachmentHandler)
+	r.DELETE("/animals/:id/attachments/:attachment_id", handler.DeleteAttachmentHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAttachmentLifecycle(t *testing.T) {
+	repo := &memoryAttachmentRepo{attachments: map[int64]animal.Attachment{}}
+	blobs := blob.NewFileStore(t.TempDir())
+	content := append(pngHeader, "rest of the image"...)
+
+	body, contentType := multipartFile(t, "file", "../lion.png", content)
+	req := httptest.NewRequest("POST", "/animals/7/attachments", body)
+	req.Header.Set("Content-Type", contentType)
+	w := serveAttachments(mockRepo{}, repo, blobs, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/animals/7/attachments/1", w.Header().Get("Location"))
+	var created animal.Attachment
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "lion.png", created.Filename)
+	assert.Equal(t, "image/png", created.ContentType)
+	assert.Equal(t, int64(len(content)), created.Size)
+	assert.Len(t, created.Checksum, 64)
+	assert.NotContains(t, w.Body.String(), "storage_key")
+	key := repo.attachments[1].StorageKey
+
+	w = serveAttachments(mockRepo{}, repo, blobs, httptest.NewRequest("GET", "/animals/7/attachments", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"filename":"lion.png"`)
+
+	w = serveAttachments(mockRepo{}, repo, blobs, httptest.NewRequest("GET", "/animals/7/attachments/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=lion.png`, w.Header().Get("Content-Disposition"))
+	etag := w.Header().Get("ETag")
+
+	req = httptest.NewRequest("GET", "/animals/7/attachments/1", nil)
+	req.Header.Set("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, serveAttachments(mockRepo{}, repo, blobs, req).Code)
+
+	assert.Equal(t, http.StatusNotFound, serveAttachments(mockRepo{}, repo, blobs, httptest.NewRequest("GET", "/animals/8/attachments/1", nil)).Code)
+
+	w = serveAttachments(mockRepo{}, repo, blobs, httptest.NewRequest("DELETE", "/animals/7/attachments/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, serveAttachments(mockRepo{}, repo, blobs, httptest.NewRequest("GET", "/animals/7/attachments/1", nil)).Code)
+
+	deleted, err := animal.SweepAttachmentBlobs(repo, blobs, slog.Default())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Empty(t, repo.pending)
+	_, err = blobs.Get(key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+// stuckBlobStore fails to delete the blobs under its stuck keys.
+type stuckBlobStore struct {
+	blob.Store
+	stuck []string
+}
+
+func (s stuckBlobStore) Delete(key string) error {
+	if slices.Contains(s.stuck, key) {
+		return errors.New("connection reset")
+	}
+	return s.Store.Delete(key)
+}
+
+func TestSweepAttachmentBlobs_FailedDelete(t *testing.T) {
+	repo := &memoryAttachmentRepo{pending: []string{"animals/7/a", "animals/7/b", "animals/7/c"}}
+	blobs := stuckBlobStore{Store: blob.NewFileStore(t.TempDir()), stuck: []string{"animals/7/a"}}
+
+	deleted, err := animal.SweepAttachmentBlobs(repo, blobs, slog.Default())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{"animals/7/a"}, repo.pending)
+	assert.Equal(t, map[string]int{"animals/7/a": 1}, repo.failed)
+
+	// the failed key waits for its retry delay while newer deletions go ahead
+	repo.pending = append(repo.pending, "animals/7/d")
+	deleted, err = animal.SweepAttachmentBlobs(repo, blobs, slog.Default())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"animals/7/a"}, repo.pending)
+	assert.Equal(t, map[string]int{"animals/7/a": 1}, repo.failed)
+}
+
+func TestUploadAttachmentHandler_Rejected(t *testing.T) {
+	oversized := append(pngHeader, make([]byte, animal.MaxAttachmentSize)...)
+
+	cases := []struct {
+		name        string
+		repo        animal.AnimalRepository
+		field       string
+		content     []byte
+		contentType string
+		status      int
+	}{
+		{"unknown animal", notFoundRepo{}, "file", pngHeader, "", http.StatusNotFound},
+		{"text file", mockRepo{}, "file", []byte("just some notes"), "", http.StatusUnsupportedMediaType},
+		{"too large", mockRepo{}, "file", oversized, "", http.StatusRequestEntityTooLarge},
+		{"empty", mockRepo{}, "file", nil, "", http.StatusBadRequest},
+		{"wrong field", mockRepo{}, "photo", pngHeader, "", http.StatusBadRequest},
+		{"not multipart", mockRepo{}, "file", pngHeader, "image/png", http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range cases {
+		repo := &memoryAttachmentRepo{attachments: map[int64]animal.Attachment{}}
+		dir := t.TempDir()
+
+		body, contentType := multipartFile(t, tc.field, "file.bin", tc.content)
+		if tc.contentType != "" {
+			body, contentType = bytes.NewBuffer(tc.content), tc.contentType
+		}
+		req := httptest.NewRequest("POST", "/animals/7/attachments", body)
+		req.Header.Set("Content-Type", contentType)
+		w := serveAttachments(tc.repo, repo, blob.NewFileStore(dir), req)
+
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), tc.name)
+		assert.Empty(t, repo.attachments, tc.name)
+		entries, _ := os.ReadDir(dir)
+		assert.Empty(t, entries, tc.name)
+	}
+}
+
+func TestListAnimalsHandler_Tags(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?tag=Featured&tag=quarantine&tag=featured&tag_match=any", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"featured", "quarantine"}, repo.opts.Tags)
+	assert.Equal(t, animal.TagMatchAny, repo.opts.TagMatch)
+
+	for target, message := range map[string]string{
+		"/animals?tag=a&tag_match=some":      "tag_match must be any or all",
+		"/animals?tag_match=all":             "tag_match needs at least one tag",
+		"/animals?tag=special%20needs":       `tag \"special needs\" is not a valid tag`,
+		"/animals?tag=a&age_gte=1&age_gte=2": `query parameter \"age_gte\" must be given only once`,
+	} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+
+		handler.ListAnimalsHandler(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		assert.Contains(t, w.Body.String(), message, target)
+	}
+}
+
+type mockTagRepo struct {
+	added *[]string
+}
+
+func (m mockTagRepo) AnimalTags(animalID int64) ([]string, error) {
+	return []string{"featured"}, nil
+}
+func (m mockTagRepo) AddTags(animalID int64, names []string) ([]string, error) {
+	*m.added = names
+	return append([]string{"featured"}, names...), nil
+}
+func (m mockTagRepo) RemoveTag(animalID int64, name string) ([]string, error) {
+	if name != "featured" {
+		return nil, fmt.Errorf("%w: animal %d is not tagged %q", animal.ErrTagNotFound, animalID, name)
+	}
+	return []string{}, nil
+}
+func (m mockTagRepo) ListTags(afterID int64, limit int) (animal.TagPage, error) {
+	return animal.TagPage{Tags: []animal.Tag{{ID: 1, Name: "featured", Count: 3}}, HasMore: true}, nil
+}
+
+func tagRouter(repo animal.AnimalRepository, tags animal.TagRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewTagHandler(mockModule{}, tags, repo)
+	r.GET("/animals/:id/tags", handler.ListAnimalTagsHandler)
+	r.POST("/animals/:id/tags", handler.AddTagsHandler)
+	r.DELETE("/animals/:id/tags/:tag", handler.RemoveTagHandler)
+	r.GET("/tags", handler.ListTagsHandler)
+	return r
+}
+
+func TestAddTagsHandler(t *testing.T) {
+	var added []string
+	tags := mockTagRepo{added: &added}
+
+	w := serve(tagRouter(mockRepo{}, tags), "POST", "/animals/7/tags", `{"tags":[" Special-Needs ","quarantine"]}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"special-needs", "quarantine"}, added)
+	assert.JSONEq(t, `{"data":["featured","special-needs","quarantine"]}`, w.Body.String())
+
+	w = serve(tagRouter(mockRepo{}, tags), "POST", "/animals/7/tags", `{"tags":["ok","-bad","no spaces"]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"tags[1]"`)
+	assert.Contains(t, w.Body.String(), `"field":"tags[2]"`)
+
+	w = serve(tagRouter(mockRepo{}, tags), "POST", "/animals/7/tags", `{"tags":[]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "must have at least 1 items")
+
+	assert.Equal(t, http.StatusNotFound, serve(tagRouter(notFoundRepo{}, tags), "POST", "/animals/7/tags", `{"tags":["ok"]}`).Code)
+}
+
+func TestRemoveTagHandler(t *testing.T) {
+	tags := mockTagRepo{}
+
+	w := serve(tagRouter(mockRepo{}, tags), "DELETE", "/animals/7/tags/Featured", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[]}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(tagRouter(mockRepo{}, tags), "DELETE", "/animals/7/tags/quarantine", "").Code)
+}
+
+func TestListTagsHandler(t *testing.T) {
+	w := serve(tagRouter(mockRepo{}, mockTagRepo{}), "GET", "/tags?limit=1", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[{"id":1,"name":"featured","count":3}],"next_cursor":"eyJpZCI6MX0"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serve(tagRouter(mockRepo{}, mockTagRepo{}), "GET", "/tags?name=x", "").Code)
+}
+
+// pedigreeRepo answers Ancestors with a fixed set of parent links.
+type pedigreeRepo struct {
+	mockRepo
+	edges []animal.PedigreeEdge
+}
+
+func (m pedigreeRepo) Ancestors(id int64, depth int) (animal.PedigreeGraph, error) {
+	nodes := []animal.PedigreeNode{{Animal: animal.Animal{ID: id}}}
+	for _, e := range m.edges {
+		nodes = append(nodes, animal.PedigreeNode{Animal: animal.Animal{ID: e.ParentID}, Depth: e.Depth})
+	}
+	return animal.PedigreeGraph{RootID: id, Depth: depth, Nodes: nodes, Edges: m.edges}, nil
+}
+
+func parents(child, sire, dam int64, depth int) []animal.PedigreeEdge {
+	return []animal.PedigreeEdge{
+		{ParentID: sire, ChildID: child, Relation: animal.RelationSire, Depth: depth},
+		{ParentID: dam, ChildID: child, Relation: animal.RelationDam, Depth: depth},
+	}
+}
+
+func TestInbreedingHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		edges       []animal.PedigreeEdge
+		coefficient float64
+		generations int
+	}{
+		{"founder", nil, 0, 0},
+		{"unrelated parents", parents(5, 3, 4, 1), 0, 1},
+		{"full siblings", slices.Concat(parents(5, 3, 4, 1), parents(3, 1, 2, 2), parents(4, 1, 2, 2)), 0.25, 2},
+		{"half siblings", slices.Concat(parents(5, 3, 4, 1), parents(3, 1, 2, 2), parents(4, 1, 6, 2)), 0.125, 2},
+		{"parent and offspring", slices.Concat(parents(5, 1, 3, 1), parents(3, 1, 2, 2)), 0.25, 2},
+	}
+	for _, tc := range cases {
+		handler := animal.NewAnimalHandler(mockModule{}, pedigreeRepo{edges: tc.edges})
+
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Params = gin.Params{gin.Param{Key: "id", Value: "5"}}
+		ctx.Request = httptest.NewRequest("GET", "/animals/5/inbreeding", nil)
+
+		handler.InbreedingHandler(ctx)
+
+		assert.Equal(t, http.StatusOK, w.Code, tc.name)
+		var got animal.Inbreeding
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got), tc.name)
+		assert.Equal(t, animal.Inbreeding{AnimalID: 5, Coefficient: tc.coefficient, Generations: tc.generations}, got, tc.name)
+	}
+}
+
+func TestAncestorsHandler(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, pedigreeRepo{edges: parents(5, 3, 4, 1)})
+
+	cases := []struct {
+		query string
+		code  int
+		depth int
+	}{
+		{"", http.StatusOK, animal.DefaultPedigreeDepth},
+		{"?depth=10", http.StatusOK, 10},
+		{"?depth=0", http.StatusBadRequest, 0},
+		{"?depth=11", http.StatusBadRequest, 0},
+		{"?depth=two", http.StatusBadRequest, 0},
+		{"?generations=2", http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Params = gin.Params{gin.Param{Key: "id", Value: "5"}}
+		ctx.Request = httptest.NewRequest("GET", "/animals/5/ancestors"+tc.query, nil)
+
+		handler.AncestorsHandler(ctx)
+
+		assert.Equal(t, tc.code, w.Code, tc.query)
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var got animal.PedigreeGraph
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got), tc.query)
+		assert.Equal(t, tc.depth, got.Depth, tc.query)
+		assert.Len(t, got.Nodes, 3, tc.query)
+		assert.Len(t, got.Edges, 2, tc.query)
+	}
+}
+
+func TestAncestorsHandler_NotFound(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, notFoundRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "5"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/5/ancestors", nil)
+
+	handler.AncestorsHandler(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// deletedSireRepo answers Ancestors with a soft deleted sire.
+type deletedSireRepo struct {
+	mockRepo
+}
+
+func (m deletedSireRepo) Ancestors(id int64, depth int) (animal.PedigreeGraph, error) {
+	return animal.PedigreeGraph{RootID: id, Depth: depth,
+		Nodes: []animal.PedigreeNode{{Animal: animal.Animal{ID: id, Name: "Cub"}}, {Animal: animal.Animal{ID: 3}, Depth: 1, Deleted: true}},
+		Edges: []animal.PedigreeEdge{{ParentID: 3, ChildID: id, Relation: animal.RelationSire, Depth: 1}},
+	}, nil
+}
+
+func TestAncestorsHandler_DeletedAncestor(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, deletedSireRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/1/ancestors?depth=1", nil)
+
+	handler.AncestorsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Cub"`)
+	assert.Contains(t, w.Body.String(), `{"id":3,"depth":1,"deleted":true}`)
+}
+
+func TestPatchAnimalHandler_Parents(t *testing.T) {
+	repo := recordingRepo{patch: &animal.AnimalPatch{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"sire_id":3,"dam_id":4}`)
+
+	sire, dam := int64(3), int64(4)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{SireID: &sire, DamID: &dam}, repo.patch.Set)
+
+	w = patchAnimal(handler, "application/merge-patch+json", `{"dam_id":null}`)
+
+	none := int64(0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.AnimalFields{DamID: &none}, repo.patch.Set)
+
+	w = patchAnimal(handler, "application/merge-patch+json", `{"sire_id":-1}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "sire_id")
+}
+
+// mockMedicalRepo records the options of list calls and keeps one vaccination.
+type mockMedicalRepo struct {
+	list *animal.MedicalRecordListOptions
+	due  *animal.DueVaccinationOptions
+}
+
+func date(s string) *animal.Date {
+	d, _ := animal.ParseDate(s)
+	return &d
+}
+
+var rabies = animal.MedicalRecord{ID: 3, AnimalID: 7, Kind: "vaccination", Title: "Rabies", AdministeredOn: *date("2024-05-01"), DueOn: date("2025-05-01"), CreatedAt: mockTime, UpdatedAt: mockTime}
+
+func (m mockMedicalRepo) CreateMedicalRecord(animalID int64, r animal.MedicalRecordRequest) (animal.MedicalRecord, error) {
+	record := animal.MedicalRecord{ID: 3, AnimalID: animalID, Kind: r.Kind, Title: r.Title, Notes: r.Notes, AdministeredOn: *date(r.AdministeredOn), CreatedAt: mockTime, UpdatedAt: mockTime}
+	if r.DueOn != nil {
+		record.DueOn = date(*r.DueOn)
+	}
+	return record, nil
+}
+func (m mockMedicalRepo) ListMedicalRecords(animalID int64, opts animal.MedicalRecordListOptions) (animal.MedicalRecordPage, error) {
+	*m.list = opts
+	return animal.MedicalRecordPage{Records: []animal.MedicalRecord{rabies}, HasMore: true}, nil
+}
+func (m mockMedicalRepo) GetMedicalRecord(animalID, id int64) (animal.MedicalRecord, error) {
+	if id != rabies.ID {
+		return animal.MedicalRecord{}, fmt.Errorf("%w: id=%d", animal.ErrMedicalRecordNotFound, id)
+	}
+	return rabies, nil
+}
+func (m mockMedicalRepo) UpdateMedicalRecord(animalID, id int64, r animal.MedicalRecordRequest) (animal.MedicalRecord, error) {
+	return m.CreateMedicalRecord(animalID, r)
+}
+func (m mockMedicalRepo) DeleteMedicalRecord(animalID, id int64) error {
+	_, err := m.GetMedicalRecord(animalID, id)
+	return err
+}
+func (m mockMedicalRepo) DueVaccinations(opts animal.DueVaccinationOptions) (animal.MedicalRecordPage, error) {
+	*m.due = opts
+	return animal.MedicalRecordPage{Records: []animal.MedicalRecord{rabies}, HasMore: true}, nil
+}
+
+func medicalRecordRouter(repo animal.AnimalRepository, records animal.MedicalRecordRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewMedicalRecordHandler(mockModule{}, records, repo)
+	r.POST("/animals/:id/medical-records", handler.CreateMedicalRecordHandler)
+	r.GET("/animals/:id/medical-records", handler.ListMedicalRecordsHandler)
+	r.GET("/animals/:id/medical-records/:record_id", handler.GetMedicalRecordHandler)
+	r.PUT("/animals/:id/medical-records/:record_id", handler.UpdateMedicalRecordHandler)
+	r.DELETE("/animals/:id/medical-records/:record_id", handler.DeleteMedicalRecordHandler)
+	r.GET("/vaccinations/due", handler.DueVaccinationsHandler)
+	return r
+}
+
+func TestCreateMedicalRecordHandler(t *testing.T) {
+	records := mockMedicalRepo{}
+
+	w := serve(medicalRecordRouter(mockRepo{}, records), "POST", "/animals/7/medical-records",
+		`{"kind":"vaccination","title":"Rabies","administered_on":"2025-03-01","due_on":"2026-03-01"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/animals/7/medical-records/3", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"id":3,"animal_id":7,"kind":"vaccination","title":"Rabies","notes":"",
+		"administered_on":"2025-03-01","due_on":"2026-03-01",
+		"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+
+	cases := []struct {
+		body  string
+		field string
+	}{
+		{`{"kind":"checkup","title":"Teeth","administered_on":"2025-03-01"}`, "kind"},
+		{`{"kind":"diagnosis","title":"Otitis"}`, "administered_on"},
+		{`{"kind":"diagnosis","title":"Otitis","administered_on":"01/03/2025"}`, "administered_on"},
+		{`{"kind":"vaccination","title":"Rabies","administered_on":"2025-03-01","due_on":"2025-02-28"}`, "due_on"},
+	}
+	for _, tc := range cases {
+		w := serve(medicalRecordRouter(mockRepo{}, records), "POST", "/animals/7/medical-records", tc.body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.Contains(t, w.Body.String(), `"field":"`+tc.field+`"`, tc.body)
+	}
+}
+
+func TestMedicalRecordHandlers(t *testing.T) {
+	records := mockMedicalRepo{}
+
+	w := serve(medicalRecordRouter(mockRepo{}, records), "GET", "/animals/7/medical-records/3", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"due_on":"2025-05-01"`)
+
+	w = serve(medicalRecordRouter(mockRepo{}, records), "PUT", "/animals/7/medical-records/3",
+		`{"kind":"treatment","title":"Antibiotics","notes":"twice a day","administered_on":"2025-03-01"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"due_on":null`)
+
+	assertStatuses(t, medicalRecordRouter(mockRepo{}, records), []statusCase{
+		{"DELETE", "/animals/7/medical-records/3", "", http.StatusOK},
+		{"DELETE", "/animals/7/medical-records/4", "", http.StatusNotFound},
+		{"GET", "/animals/7/medical-records/x", "", http.StatusBadRequest},
+	})
+}
+
+func TestListMedicalRecordsHandler(t *testing.T) {
+	records := mockMedicalRepo{list: &animal.MedicalRecordListOptions{}}
+
+	w := serve(medicalRecordRouter(mockRepo{}, records), "GET", "/animals/7/medical-records?kind=vaccination&limit=1", "")
+
+	kind := "vaccination"
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, animal.MedicalRecordListOptions{Limit: 1, Kind: &kind}, *records.list)
+
+	var page animal.MedicalRecordListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.NotNil(t, page.NextCursor) {
+		w = serve(medicalRecordRouter(mockRepo{}, records), "GET", "/animals/7/medical-records?cursor="+*page.NextCursor, "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, &animal.MedicalRecordCursor{Date: *date("2024-05-01"), ID: 3}, records.list.After)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, serve(medicalRecordRouter(mockRepo{}, records), "GET", "/animals/7/medical-records?kind=checkup", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(medicalRecordRouter(mockRepo{}, records), "GET", "/animals/7/medical-records?title=x", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(medicalRecordRouter(notFoundRepo{}, records), "GET", "/animals/7/medical-records", "").Code)
+}
+
+func TestDueVaccinationsHandler(t *testing.T) {
+	records := mockMedicalRepo{due: &animal.DueVaccinationOptions{}}
+
+	w := serve(medicalRecordRouter(mockRepo{}, records), "GET", "/vaccinations/due?before=2025-06-01", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, *date("2025-06-01"), records.due.Before)
+
+	var page animal.MedicalRecordListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.NotNil(t, page.NextCursor) {
+		w = serve(medicalRecordRouter(mockRepo{}, records), "GET", "/vaccinations/due?cursor="+*page.NextCursor, "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, &animal.MedicalRecordCursor{Date: *date("2025-05-01"), ID: 3}, records.due.After)
+		assert.Equal(t, time.Now().UTC().Format(animal.DateLayout), records.due.Before.String())
+	}
+
+	assert.Equal(t, http.StatusBadRequest, serve(medicalRecordRouter(mockRepo{}, records), "GET", "/vaccinations/due?before=soon", "").Code)
+	// a cursor of the per animal list is ordered differently
+	cursor := animal.EncodeCursor(animal.Cursor{ID: 3, Sort: "administered_on", Values: []any{"2024-05-01"}})
+	assert.Equal(t, http.StatusBadRequest, serve(medicalRecordRouter(mockRepo{}, records), "GET", "/vaccinations/due?cursor="+cursor, "").Code)
+}
+
+// mockOwnerRepo knows owner 4, who has adoptions.
+type mockOwnerRepo struct{}
+
+var alice = animal.Owner{ID: 4, Name: "Alice", Address: "1 Main St", CreatedAt: mockTime, UpdatedAt: mockTime}
+
+func (m mockOwnerRepo) CreateOwner(r animal.OwnerRequest) (animal.Owner, error) {
+	return animal.Owner{ID: 4, Name: r.Name, Email: r.Email, Phone: r.Phone, Address: r.Address, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockOwnerRepo) UpdateOwner(id int64, r animal.OwnerRequest) (animal.Owner, error) {
+	if _, err := m.GetOwner(id); err != nil {
+		return animal.Owner{}, err
+	}
+	return m.CreateOwner(r)
+}
+func (m mockOwnerRepo) ListOwners(opts animal.OwnerListOptions) (animal.OwnerPage, error) {
+	return animal.OwnerPage{Owners: []animal.Owner{alice}}, nil
+}
+func (m mockOwnerRepo) GetOwner(id int64) (animal.Owner, error) {
+	if id != alice.ID {
+		return animal.Owner{}, fmt.Errorf("%w: id=%d", animal.ErrOwnerNotFound, id)
+	}
+	return alice, nil
+}
+func (m mockOwnerRepo) DeleteOwner(id int64) error {
+	return fmt.Errorf("%w: id=%d", animal.ErrOwnerHasAdoptions, id)
+}
+
+func ownerRouter(repo animal.AnimalRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewOwnerHandler(mockModule{}, mockOwnerRepo{}, repo)
+	r.POST("/owners", handler.CreateOwnerHandler)
+	r.GET("/owners", handler.ListOwnersHandler)
+	r.GET("/owners/:id", handler.GetOwnerHandler)
+	r.PUT("/owners/:id", handler.UpdateOwnerHandler)
+	r.DELETE("/owners/:id", handler.DeleteOwnerHandler)
+	r.GET("/owners/:id/animals", handler.ListOwnerAnimalsHandler)
+	return r
+}
+
+func TestCreateOwnerHandler(t *testing.T) {
+	w := serve(ownerRouter(mockRepo{}), "POST", "/owners", `{"name":"Alice","email":"alice@example.com"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/owners/4", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"id":4,"name":"Alice","email":"alice@example.com","phone":null,"address":"",
+		"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+
+	w = serve(ownerRouter(mockRepo{}), "POST", "/owners", `{"name":"Alice","email":"alice"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "must be an email address")
+}
+
+func TestOwnerHandlers(t *testing.T) {
+	assertStatuses(t, ownerRouter(mockRepo{}), []statusCase{
+		{"GET", "/owners/4", "", http.StatusOK},
+		{"PUT", "/owners/5", `{"name":"Bob"}`, http.StatusNotFound},
+		{"DELETE", "/owners/4", "", http.StatusConflict},
+		{"GET", "/owners?email=Alice@example.com", "", http.StatusOK},
+		{"GET", "/owners?name=Alice", "", http.StatusBadRequest},
+	})
+}
+
+func TestListOwnerAnimalsHandler(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+
+	w := serve(ownerRouter(repo), "GET", "/owners/4/animals?name=Cat", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, repo.opts.Owner) {
+		assert.Equal(t, int64(4), *repo.opts.Owner)
+	}
+	assert.Equal(t, &animal.StringFilter{Match: animal.MatchExact, Value: "Cat"}, repo.opts.Name)
+
+	assert.Equal(t, http.StatusNotFound, serve(ownerRouter(repo), "GET", "/owners/5/animals", "").Code)
+}
+
+// mockAdoptionRepo has the animal adopted since 2025-01-01, so any adoption
+// from then on overlaps it.
+type mockAdoptionRepo struct{}
+
+func (m mockAdoptionRepo) CreateAdoption(animalID int64, r animal.AdoptionRequest) (animal.Adoption, error) {
+	if r.OwnerID != alice.ID {
+		return animal.Adoption{}, animal.ErrUnknownOwner
+	}
+	if r.ReturnedOn == nil || *r.ReturnedOn > "2025-01-01" {
+		return animal.Adoption{}, fmt.Errorf("%w: id=%d", animal.ErrAlreadyAdopted, animalID)
+	}
+	return animal.Adoption{ID: 2, AnimalID: animalID, OwnerID: r.OwnerID, AdoptedOn: *date(r.AdoptedOn), ReturnedOn: date(*r.ReturnedOn), FeeCents: r.FeeCents, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockAdoptionRepo) UpdateAdoption(animalID, id int64, r animal.AdoptionRequest) (animal.Adoption, error) {
+	return m.CreateAdoption(animalID, r)
+}
+func (m mockAdoptionRepo) ListAdoptions(animalID int64) ([]animal.Adoption, error) {
+	return []animal.Adoption{{ID: 1, AnimalID: animalID, OwnerID: alice.ID, AdoptedOn: *date("2025-01-01"), CreatedAt: mockTime, UpdatedAt: mockTime}}, nil
+}
+func (m mockAdoptionRepo) GetAdoption(animalID, id int64) (animal.Adoption, error) {
+	return animal.Adoption{}, fmt.Errorf("%w: id=%d", animal.ErrAdoptionNotFound, id)
+}
+func (m mockAdoptionRepo) DeleteAdoption(animalID, id int64) error {
+	_, err := m.GetAdoption(animalID, id)
+	return err
+}
+
+func adoptionRouter(repo animal.AnimalRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewAdoptionHandler(mockModule{}, mockAdoptionRepo{}, repo)
+	r.POST("/animals/:id/adoptions", handler.CreateAdoptionHandler)
+	r.GET("/animals/:id/adoptions", handler.ListAdoptionsHandler)
+	r.GET("/animals/:id/adoptions/:adoption_id", handler.GetAdoptionHandler)
+	r.PUT("/animals/:id/adoptions/:adoption_id", handler.UpdateAdoptionHandler)
+	r.DELETE("/animals/:id/adoptions/:adoption_id", handler.DeleteAdoptionHandler)
+	return r
+}
+
+func TestCreateAdoptionHandler(t *testing.T) {
+	w := serve(adoptionRouter(mockRepo{}), "POST", "/animals/7/adoptions",
+		`{"owner_id":4,"adopted_on":"2024-06-01","returned_on":"2024-07-01","fee_cents":7500}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/animals/7/adoptions/2", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"id":2,"animal_id":7,"owner_id":4,"adopted_on":"2024-06-01","returned_on":"2024-07-01",
+		"fee_cents":7500,"notes":"","created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+
+	w = serve(adoptionRouter(mockRepo{}), "POST", "/animals/7/adoptions", `{"owner_id":4,"adopted_on":"2025-03-01"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "already adopted")
+
+	cases := []struct {
+		body  string
+		field string
+	}{
+		{`{"owner_id":5,"adopted_on":"2024-06-01","returned_on":"2024-07-01"}`, "owner_id"},
+		{`{"owner_id":4,"adopted_on":"2024-06-01","returned_on":"2024-05-01"}`, "returned_on"},
+		{`{"owner_id":4,"adopted_on":"2024-06-01","fee_cents":-1}`, "fee_cents"},
+		{`{"owner_id":4}`, "adopted_on"},
+	}
+	for _, tc := range cases {
+		w := serve(adoptionRouter(mockRepo{}), "POST", "/animals/7/adoptions", tc.body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.Contains(t, w.Body.String(), `"field":"`+tc.field+`"`, tc.body)
+	}
+}
+
+func TestAdoptionHandlers(t *testing.T) {
+	w := serve(adoptionRouter(mockRepo{}), "GET", "/animals/7/adoptions", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"returned_on":null`)
+	assert.Equal(t, http.StatusNotFound, serve(adoptionRouter(notFoundRepo{}), "GET", "/animals/7/adoptions", "").Code)
+
+	w = serve(adoptionRouter(mockRepo{}), "PUT", "/animals/7/adoptions/1", `{"owner_id":4,"adopted_on":"2025-01-01","returned_on":"2025-02-01"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	assertStatuses(t, adoptionRouter(mockRepo{}), []statusCase{
+		{"DELETE", "/animals/7/adoptions/3", "", http.StatusNotFound},
+		{"GET", "/animals/7/adoptions/x", "", http.StatusBadRequest},
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, animal.CanTransition(animal.StatusIntake, animal.StatusQuarantine))
+	assert.True(t, animal.CanTransition(animal.StatusQuarantine, animal.StatusAvailable))
+	assert.True(t, animal.CanTransition(animal.StatusAvailable, animal.StatusAdopted))
+	assert.True(t, animal.CanTransition(animal.StatusAdopted, animal.StatusAvailable))
+	assert.False(t, animal.CanTransition(animal.StatusAdopted, animal.StatusIntake))
+	assert.False(t, animal.CanTransition(animal.StatusQuarantine, animal.StatusAdopted))
+	assert.False(t, animal.CanTransition(animal.StatusAvailable, animal.StatusAvailable))
+	for _, status := range animal.Statuses {
+		assert.False(t, animal.CanTransition(animal.StatusDeceased, status), status)
+	}
+}
+
+func transition(handler *animal.AnimalHandler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals/1/transitions", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	handler.TransitionHandler(ctx)
+	return w
+}
+
+func TestTransitionHandler(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := transition(handler, `{"to":"adopted","reason":"went home with Alice"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"status":"adopted"`)
+
+	w = transition(handler, `{"to":"intake","reason":"mistake"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot go from available to intake")
+
+	w = httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "2"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals/2/transitions", strings.NewReader(`{"to":"adopted","reason":"went home"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	handler.TransitionHandler(ctx)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "no adoption that lasts today")
+
+	cases := []struct {
+		body  string
+		field string
+	}{
+		{`{"to":"sold","reason":"x"}`, "to"},
+		{`{"to":"adopted"}`, "reason"},
+	}
+	for _, tc := range cases {
+		w := transition(handler, tc.body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tc.body)
+		assert.Contains(t, w.Body.String(), `"field":"`+tc.field+`"`, tc.body)
+	}
+}
+
+func TestStatusHistoryHandler(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/1/status-history", nil)
+
+	handler.StatusHistoryHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":[{"id":1,"animal_id":1,"from":"intake","to":"available","reason":"healthy","changed_at":"2025-01-02T03:04:05Z"}]}`, w.Body.String())
+}
+
+func TestListAnimalsHandler_Status(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?status=available&status=quarantine&status=available", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"available", "quarantine"}, repo.opts.Statuses)
+}
+
+func TestPatchAnimalHandler_Status(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"status":"adopted"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "POST /animals/:id/transitions")
+}
+
+// mockEnclosureRepo knows enclosure 2, which takes two animals and houses one.
+type mockEnclosureRepo struct{}
+
+var aviary = animal.Enclosure{ID: 2, Name: "Aviary", Capacity: 2, AllowedSpecies: pq.Int64Array{7}, CreatedAt: mockTime, UpdatedAt: mockTime}
+
+func (m mockEnclosureRepo) CreateEnclosure(r animal.EnclosureRequest) (animal.Enclosure, error) {
+	return animal.Enclosure{ID: 2, Name: r.Name, Capacity: r.Capacity, AllowedSpecies: r.AllowedSpecies, CreatedAt: mockTime, UpdatedAt: mockTime}, nil
+}
+func (m mockEnclosureRepo) UpdateEnclosure(id int64, r animal.EnclosureRequest) (animal.Enclosure, error) {
+	if _, err := m.GetEnclosure(id); err != nil {
+		return animal.Enclosure{}, err
+	}
+	if r.Capacity < 1 {
+		return animal.Enclosure{}, fmt.Errorf("%w: enclosure %d houses 1 animals, more than a capacity of %d", animal.ErrEnclosureFull, id, r.Capacity)
+	}
+	return m.CreateEnclosure(r)
+}
+func (m mockEnclosureRepo) ListEnclosures(opts animal.EnclosureListOptions) (animal.EnclosurePage, error) {
+	return animal.EnclosurePage{Enclosures: []animal.Enclosure{aviary}, HasMore: opts.Limit == 1}, nil
+}
+func (m mockEnclosureRepo) GetEnclosure(id int64) (animal.Enclosure, error) {
+	if id != aviary.ID {
+		return animal.Enclosure{}, fmt.Errorf("%w: id=%d", animal.ErrEnclosureNotFound, id)
+	}
+	return aviary, nil
+}
+func (m mockEnclosureRepo) DeleteEnclosure(id int64) error {
+	return fmt.Errorf("%w: id=%d", animal.ErrEnclosureOccupied, id)
+}
+func (m mockEnclosureRepo) Occupancy() (animal.OccupancyReport, error) {
+	var report animal.OccupancyReport
+	report.Data = []animal.Occupancy{{EnclosureID: 2, Name: "Aviary", Capacity: 2, Occupants: 1, Free: 1}}
+	report.Totals.Capacity, report.Totals.Occupants, report.Totals.Free, report.Totals.Unhoused = 2, 1, 1, 3
+	return report, nil
+}
+
+func enclosureRouter(repo animal.AnimalRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := animal.NewEnclosureHandler(mockModule{}, mockEnclosureRepo{}, repo)
+	r.POST("/enclosures", handler.CreateEnclosureHandler)
+	r.GET("/enclosures", handler.ListEnclosuresHandler)
+	r.GET("/enclosures/occupancy", handler.OccupancyHandler)
+	r.GET("/enclosures/:id", handler.GetEnclosureHandler)
+	r.PUT("/enclosures/:id", handler.UpdateEnclosureHandler)
+	r.DELETE("/enclosures/:id", handler.DeleteEnclosureHandler)
+	r.GET("/enclosures/:id/animals", handler.ListEnclosureAnimalsHandler)
+	animals := animal.NewAnimalHandler(mockModule{}, repo)
+	r.POST("/animals/:id/move", animals.MoveAnimalHandler)
+	return r
+}
+
+func TestCreateEnclosureHandler(t *testing.T) {
+	w := serve(enclosureRouter(mockRepo{}), "POST", "/enclosures", `{"name":"Aviary","capacity":2,"allowed_species":[7]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/enclosures/2", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"id":2,"name":"Aviary","capacity":2,"allowed_species":[7],
+		"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z"}`, w.Body.String())
+
+	cases := map[string]string{
+		`{"name":"Aviary","capacity":0}`:                       "capacity",
+		`{"name":"Aviary","capacity":2,"allowed_species":[0]}`: "allowed_species[0]",
+		`{"capacity":2}`: "name",
+		`{"name":"Aviary","capacity":2,"allowed_species":"birds"}`: "allowed_species",
+	}
+	for body, field := range cases {
+		w := serve(enclosureRouter(mockRepo{}), "POST", "/enclosures", body)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		assert.Contains(t, w.Body.String(), `"field":"`+field+`"`, body)
+	}
+}
+
+func TestEnclosureHandlers(t *testing.T) {
+	assertStatuses(t, enclosureRouter(mockRepo{}), []statusCase{
+		{"GET", "/enclosures/2", "", http.StatusOK},
+		{"GET", "/enclosures/5", "", http.StatusNotFound},
+		{"PUT", "/enclosures/5", `{"name":"Pond","capacity":3}`, http.StatusNotFound},
+		{"DELETE", "/enclosures/2", "", http.StatusConflict},
+		{"GET", "/enclosures?name=Aviary", "", http.StatusBadRequest},
+	})
+
+	w := serve(enclosureRouter(mockRepo{}), "GET", "/enclosures?limit=1", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page animal.EnclosureListResponse
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page)) {
+		assert.Len(t, page.Data, 1)
+		assert.NotNil(t, page.NextCursor)
+	}
+}
+
+func TestOccupancyHandler(t *testing.T) {
+	w := serve(enclosureRouter(mockRepo{}), "GET", "/enclosures/occupancy", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": [{"enclosure_id": 2, "name": "Aviary", "capacity": 2, "occupants": 1, "free": 1}],
+		"totals": {"capacity": 2, "occupants": 1, "free": 1, "unhoused": 3}
+	}`, w.Body.String())
+}
+
+func TestListEnclosureAnimalsHandler(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+
+	w := serve(enclosureRouter(repo), "GET", "/enclosures/2/animals?status=available", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, repo.opts.Enclosure) {
+		assert.Equal(t, int64(2), *repo.opts.Enclosure)
+	}
+	assert.Equal(t, []string{"available"}, repo.opts.Statuses)
+
+	assert.Equal(t, http.StatusNotFound, serve(enclosureRouter(repo), "GET", "/enclosures/5/animals", "").Code)
+}
+
+func TestMoveAnimalHandler(t *testing.T) {
+	w := serve(enclosureRouter(mockRepo{}), "POST", "/animals/1/move", `{"enclosure_id":2}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"enclosure_id":2`)
+
+	w = serve(enclosureRouter(mockRepo{}), "POST", "/animals/1/move", `{"enclosure_id":null}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"enclosure_id":null`)
+
+	w = serve(enclosureRouter(mockRepo{}), "POST", "/animals/1/move", `{"enclosure_id":3}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "enclosure is full")
+
+	w = serve(enclosureRouter(mockRepo{}), "POST", "/animals/1/move", `{"enclosure_id":9}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"enclosure_id"`)
+}
+
+func TestPatchAnimalHandler_Enclosure(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"enclosure_id":2}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "POST /animals/:id/move")
+}
+
+// housedRepo houses every animal in an enclosure that only takes species 7.
+type housedRepo struct {
+	mockRepo
+}
+
+func (m housedRepo) UpdateAnimal(id int64, version int64, r animal.AnimalUpdateRequest) (animal.Animal, error) {
+	if r.SpeciesID == nil || *r.SpeciesID != 7 {
+		return animal.Animal{}, fmt.Errorf("%w: enclosure 2 does not take the species of animal %d", animal.ErrSpeciesNotAllowed, id)
+	}
+	return m.mockRepo.UpdateAnimal(id, version, r)
+}
+func (m housedRepo) PatchAnimal(id int64, version int64, p animal.AnimalPatch) (animal.Animal, error) {
+	if p.Set.SpeciesID != nil && *p.Set.SpeciesID != 7 {
+		return animal.Animal{}, fmt.Errorf("%w: enclosure 2 does not take the species of animal %d", animal.ErrSpeciesNotAllowed, id)
+	}
+	return m.mockRepo.PatchAnimal(id, version, p)
+}
+
+func TestUpdateAnimalHandler_SpeciesNotAllowed(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, housedRepo{})
+
+	w := patchAnimal(handler, "application/merge-patch+json", `{"species_id":8}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "species is not allowed in the enclosure")
+
+	w = patchAnimal(handler, "application/merge-patch+json", `{"species_id":null}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("PUT", "/animals/1", strings.NewReader(`{"name":"Panther","age":6,"description":"Stealthy","species_id":8}`))
+	ctx.Request.Header.Set("If-Match", "*")
+	ctx.Request.Header.Set("Content-Type", "application/json")
+
+	handler.UpdateAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestListAnimalsHandler_Fields(t *testing.T) {
+	repo := recordingRepo{opts: &animal.ListOptions{}}
+	handler := animal.NewAnimalHandler(mockModule{}, repo)
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?fields=name,id,name", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"name", "id"}, repo.opts.Projection.Fields)
+	assert.JSONEq(t, `{"data":[{"id":1,"name":"Cat"},{"id":2,"name":"Dog"}],"next_cursor":null}`, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+}
+
+func TestListAnimalsHandler_InvalidProjection(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	for _, target := range []string{
+		"/animals?fields=name,weight",
+		"/animals?fields=",
+		"/animals?include=owner",
+		"/animals?include=tags&as_of=2025-01-02T03:04:05Z",
+	} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+
+		handler.ListAnimalsHandler(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+}
+
+// relatedRepo lists a cub of the Lion and records the calls to Related.
+type relatedRepo struct {
+	mockRepo
+	calls *[][]string
+}
+
+func (m relatedRepo) ListAnimals(opts animal.ListOptions) (animal.AnimalPage, error) {
+	species, sire := int64(7), int64(1)
+	return animal.AnimalPage{Animals: []animal.Animal{
+		{ID: 1, Name: "Lion", Version: 4, UpdatedAt: mockTime},
+		{ID: 2, Name: "Cub", SpeciesID: &species, SireID: &sire, Version: 1, UpdatedAt: mockTime},
+	}}, nil
+}
+
+func (m relatedRepo) Related(animals []animal.Animal, include []string) (animal.RelatedResources, error) {
+	*m.calls = append(*m.calls, include)
+	return animal.RelatedResources{
+		Species: map[int64]animal.Species{7: {ID: 7, Rank: "species", ScientificName: "Panthera leo"}},
+		Parents: map[int64]animal.Animal{1: {ID: 1, Name: "Lion"}},
+		Tags:    map[int64][]string{2: {"playful"}},
+	}, nil
+}
+
+func TestListAnimalsHandler_Include(t *testing.T) {
+	var calls [][]string
+	handler := animal.NewAnimalHandler(mockModule{}, relatedRepo{calls: &calls})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?fields=name&include=tags,species,sire", nil)
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, [][]string{{"tags", "species", "sire"}}, calls)
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	var body struct {
+		Data []map[string]json.RawMessage `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Data, 2) {
+		assert.Equal(t, `"Cub"`, string(body.Data[1]["name"]))
+		assert.Contains(t, string(body.Data[1]["species"]), `"scientific_name":"Panthera leo"`)
+		assert.Contains(t, string(body.Data[1]["sire"]), `"name":"Lion"`)
+		assert.JSONEq(t, `["playful"]`, string(body.Data[1]["tags"]))
+	}
+	// members come in the order of the animal, then of the includes
+	assert.Regexp(t, `^\{"data":\[\{"name":"Lion","species":null,"sire":null,"tags":\[\]\}`, w.Body.String())
+}
+
+func TestGetAnimalHandler_Projection(t *testing.T) {
+	var calls [][]string
+	handler := animal.NewAnimalHandler(mockModule{}, relatedRepo{calls: &calls})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/1?fields=id,version", nil)
+
+	handler.GetAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"id":1,"version":4}`, w.Body.String())
+	// a projection is a representation of its own, with a tag of its own
+	assert.Regexp(t, `^"4\.[0-9a-f]{8}"$`, w.Header().Get("ETag"))
+	assert.Empty(t, calls)
+
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", "/animals/1?include=dam", nil)
+
+	handler.GetAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Lion"`)
+	assert.Contains(t, w.Body.String(), `"dam":null}`)
+	assert.Equal(t, [][]string{{"dam"}}, calls)
+}
+
+func getAnimal(handler *animal.AnimalHandler, target, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest("GET", target, nil)
+	ctx.Request.Header.Set("Accept", accept)
+	handler.GetAnimalHandler(ctx)
+	return w
+}
+
+func TestGetAnimalHandler_Formats(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	cases := []struct {
+		accept      string
+		contentType string
+		decode      func([]byte, any) error
+	}{
+		{"", "application/json; charset=utf-8", json.Unmarshal},
+		{"*/*", "application/json; charset=utf-8", json.Unmarshal},
+		{"application/yaml", "application/yaml; charset=utf-8", yaml.Unmarshal},
+		{"text/html, application/x-yaml;q=0.9", "application/yaml; charset=utf-8", yaml.Unmarshal},
+		{"application/json;q=0.5, application/msgpack", "application/msgpack", func(b []byte, v any) error {
+			var h codec.MsgpackHandle
+			h.RawToString = true
+			return codec.NewDecoderBytes(b, &h).Decode(v)
+		}},
+	}
+	tags := map[string]string{}
+	for _, tc := range cases {
+		w := getAnimal(handler, "/animals/1", tc.accept)
+
+		var body struct {
+			Name      string `json:"name" yaml:"name" codec:"name"`
+			Age       int    `json:"age" yaml:"age" codec:"age"`
+			BirthDate string `json:"birth_date" yaml:"birth_date" codec:"birth_date"`
+			SpeciesID *int64 `json:"species_id" yaml:"species_id" codec:"species_id"`
+		}
+		assert.Equal(t, http.StatusOK, w.Code, tc.accept)
+		assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"), tc.accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"), tc.accept)
+		if tc.contentType == "application/json; charset=utf-8" {
+			assert.Equal(t, `"4"`, w.Header().Get("ETag"), tc.accept)
+		} else {
+			assert.Regexp(t, `^"4\.[0-9a-f]{8}"$`, w.Header().Get("ETag"), tc.accept)
+		}
+		tags[tc.contentType] = w.Header().Get("ETag")
+		if assert.NoError(t, tc.decode(w.Body.Bytes(), &body), tc.accept) {
+			assert.Equal(t, "Lion", body.Name, tc.accept)
+			assert.Equal(t, 7, body.Age, tc.accept)
+			assert.Equal(t, "0001-01-01", body.BirthDate, tc.accept)
+			assert.Nil(t, body.SpeciesID, tc.accept)
+		}
+	}
+	assert.Len(t, slices.Compact(slices.Sorted(maps.Values(tags))), 3)
+
+	w := getAnimal(handler, "/animals/1?fields=id,name,species_id", "text/csv")
+
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,species_id\n1,Lion,\n", w.Body.String())
+
+	w = getAnimal(handler, "/animals/1?fields=name,age,species_id", "application/xml")
+
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, xml.Header+"<animal>\n  <name>Lion</name>\n  <age>7</age>\n  <species_id nil=\"true\"></species_id>\n</animal>", w.Body.String())
+}
+
+func TestGetAnimalHandler_NotAcceptable(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	for _, accept := range []string{"text/html", "application/json;q=0, image/*;q=0.5"} {
+		w := getAnimal(handler, "/animals/1", accept)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code, accept)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), accept)
+		assert.Contains(t, w.Body.String(), "text/csv", accept)
+	}
+}
+
+func TestListAnimalsHandler_CSV(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/animals?fields=id,name,description&limit=1", nil)
+	ctx.Request.Header.Set("Accept", "text/csv")
+
+	handler.ListAnimalsHandler(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,name,description\n1,Cat,Domestic\n", w.Body.String())
+	assert.Contains(t, w.Header().Get("Link"), `rel="next"`)
+}
+
+func TestCreateAnimalHandler_Formats(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+	create := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("POST", "/animals", bytes.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", contentType)
+		handler.CreateAnimalHandler(ctx)
+		return w
+	}
+
+	var packed []byte
+	assert.NoError(t, codec.NewEncoderBytes(&packed, &codec.MsgpackHandle{}).Encode(map[string]any{"name": "Tiger", "age": 4, "description": "Striped"}))
+
+	cases := []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/csv", []byte("name,age,description,species_id\nTiger,4,Striped,\n")},
+		{"application/yaml", []byte("name: Tiger\nage: 4\ndescription: Striped\n")},
+		{"text/xml; charset=utf-8", []byte(`<animal><name>Tiger</name><age>4</age><description>Striped</description></animal>`)},
+		{"application/x-msgpack", packed},
+	}
+	for _, tc := range cases {
+		w := create(tc.contentType, tc.body)
+
+		assert.Equal(t, http.StatusCreated, w.Code, tc.contentType)
+		assert.Contains(t, w.Body.String(), `"name":"Tiger","age":4,`, tc.contentType)
+		assert.Contains(t, w.Body.String(), `"description":"Striped"`, tc.contentType)
+	}
+
+	// unquoted YAML dates are read as written
+	w := create("application/yaml", []byte("name: Tiger\nbirth_date: 2020-06-15\n"))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"birth_date":"2020-06-15"`)
+
+	w = create("text/csv", []byte("name,age\nTiger,old\n"))
+
+	var p problem.Problem
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, []problem.FieldError{{Field: "age", Code: "invalid_type", Message: "must be a integer"}}, p.Errors)
+
+	w = create("text/csv", []byte("name\nTiger\nPuma\n"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = create("text/plain", []byte("Tiger"))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestBatchCreateAnimalsHandler_CSV(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{gin.Param{Key: "action", Value: ":batch"}}
+	ctx.Request = httptest.NewRequest("POST", "/animals:batch?atomic=true", strings.NewReader("name,age\nTiger,4\nPuma,2\n"))
+	ctx.Request.Header.Set("Content-Type", "text/csv")
+	ctx.Request.Header.Set("Accept", "text/csv")
+
+	handler.CollectionActionHandler(ctx)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.True(t, strings.HasPrefix(lines[0], "id,name,age,birth_date,"), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "10,Tiger,4,2021-01-02,true,"), lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "11,Puma,2,2023-01-02,true,"), lines[2])
+	}
+}
+
+func TestCSVRepresentation(t *testing.T) {
+	var calls [][]string
+	handler := animal.NewAnimalHandler(mockModule{}, relatedRepo{calls: &calls})
+
+	w := getAnimal(handler, "/animals/1?fields=id,name&include=tags,sire", "text/csv")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,name,sire,tags\n1,Lion,,[]\n", w.Body.String())
+
+	w = getAnimal(handler, "/animals/1", "text/csv")
+
+	assert.Equal(t, strings.Join([]string{
+		"id,name,age,birth_date,birth_date_estimated,description,species_id,sire_id,dam_id,status,enclosure_id,version,created_at,updated_at,deleted_at",
+		"1,Lion,7,0001-01-01,false,Fierce,,,,,,4,2025-01-02T03:04:05Z,2025-01-02T03:04:05Z,",
+	}, "\n")+"\n", w.Body.String())
+
+	list := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", target, nil)
+		ctx.Request.Header.Set("Accept", "text/csv")
+		handler.ListAnimalsHandler(ctx)
+		return w
+	}
+
+	w = list("/animals?fields=name&include=tags")
+
+	assert.Equal(t, "name,tags\nLion,[]\nCub,\"[\"\"playful\"\"]\"\n", w.Body.String())
+
+	// an empty page still has its header row
+	handler = animal.NewAnimalHandler(mockModule{}, mockRepo{})
+	w = list("/animals?fields=id,name&cursor=" + animal.EncodeCursor(animal.Cursor{ID: 2}))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,name\n", w.Body.String())
+}
+
+func TestCSVRepresentation_Formulas(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("POST", "/animals", strings.NewReader("name,description\n'=cmd,@SUM(A1)\n"))
+	ctx.Request.Header.Set("Content-Type", "text/csv")
+	ctx.Request.Header.Set("Accept", "text/csv")
+
+	handler.CreateAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "\n3,'=cmd,0,")
+	assert.Contains(t, w.Body.String(), ",'@SUM(A1),")
+}
+
+func TestXMLRepresentation_FormulaCharacters(t *testing.T) {
+	handler := animal.NewAnimalHandler(mockModule{}, mockRepo{})
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("POST", "/animals", strings.NewReader("<animal><name>-Rex</name><description>@home</description></animal>"))
+	ctx.Request.Header.Set("Content-Type", "application/xml")
+	ctx.Request.Header.Set("Accept", "application/xml")
+
+	handler.CreateAnimalHandler(ctx)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "<name>-Rex</name>")
+	assert.Contains(t, w.Body.String(), "<description>@home</description>")
 }
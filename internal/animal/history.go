@@ -0,0 +1,145 @@
+package animal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	module Module
+	repo   RevisionRepository
+}
+
+func NewHistoryHandler(module Module, repo RevisionRepository) *HistoryHandler {
+	return &HistoryHandler{module: module, repo: repo}
+}
+
+// ListHistoryHandler returns the revisions of an animal, oldest first.
+func (h *HistoryHandler) ListHistoryHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	limit, after, err := parsePageParams(ctx, nil)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	var afterRevision int64
+	if after != nil {
+		afterRevision = after.ID
+	}
+
+	page, err := h.repo.ListRevisions(id, afterRevision, limit)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if page.HasMore {
+		token := EncodeCursor(Cursor{ID: page.Revisions[len(page.Revisions)-1].Revision})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, RevisionListResponse{Data: page.Revisions, NextCursor: nextCursor})
+}
+
+func (h *HistoryHandler) GetRevisionHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	rev, err := parseRevision(ctx.Param("rev"), "revision")
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	revision, err := h.repo.GetRevision(id, rev)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revision)
+}
+
+// DiffHistoryHandler lists the fields that differ between the revisions given
+// by the from and to query parameters.
+func (h *HistoryHandler) DiffHistoryHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	from, err := parseRevision(ctx.Query("from"), "from")
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	to, err := parseRevision(ctx.Query("to"), "to")
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	fromRevision, err := h.repo.GetRevision(id, from)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	toRevision, err := h.repo.GetRevision(id, to)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RevisionDiff{
+		AnimalID: id,
+		From:     from,
+		To:       to,
+		Changes:  diffSnapshots(fromRevision.Snapshot, toRevision.Snapshot),
+	})
+}
+
+func parseRevision(value, name string) (int64, error) {
+	rev, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || rev < 1 {
+		return 0, badRequest("%s must be a positive integer", name)
+	}
+	return rev, nil
+}
+
+// diffSnapshots compares two snapshots field by field, in the order of the
+// Animal fields, and reports the fields whose JSON values differ.
+func diffSnapshots(from, to Animal) []FieldChange {
+	var (
+		changes = []FieldChange{}
+		t       = reflect.TypeOf(from)
+		a, b    = reflect.ValueOf(from), reflect.ValueOf(to)
+	)
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		va, vb := a.Field(i).Interface(), b.Field(i).Interface()
+
+		ja, _ := json.Marshal(va)
+		jb, _ := json.Marshal(vb)
+		if !bytes.Equal(ja, jb) {
+			changes = append(changes, FieldChange{Field: name, From: va, To: vb})
+		}
+	}
+	return changes
+}
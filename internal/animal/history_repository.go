@@ -0,0 +1,86 @@
+package animal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RevisionRepository reads the history AnimalRepository records with every write.
+// The history of an animal outlives the animal, so purged animals keep theirs.
+type RevisionRepository interface {
+	// ListRevisions returns the revisions of an animal that follow afterRevision,
+	// oldest first. It fails with ErrAnimalNotFound when the animal has no history.
+	ListRevisions(animalID int64, afterRevision int64, limit int) (RevisionPage, error)
+	GetRevision(animalID int64, revision int64) (AnimalRevision, error)
+}
+
+type PostgresRevisionRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRevisionRepository(db *sqlx.DB) *PostgresRevisionRepository {
+	return &PostgresRevisionRepository{db: db}
+}
+
+// revisionColumns is the select list that matches revisionRow.
+const revisionColumns = `animal_id, revision, operation, snapshot, changed_at`
+
+type revisionRow struct {
+	AnimalID  int64     `db:"animal_id"`
+	Revision  int64     `db:"revision"`
+	Operation string    `db:"operation"`
+	Snapshot  []byte    `db:"snapshot"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+func (row revisionRow) revision() (AnimalRevision, error) {
+	rev := AnimalRevision{AnimalID: row.AnimalID, Revision: row.Revision, Operation: row.Operation, ChangedAt: row.ChangedAt}
+	if err := json.Unmarshal(row.Snapshot, &rev.Snapshot); err != nil {
+		return AnimalRevision{}, fmt.Errorf("failed to decode snapshot of revision %d: %w", row.Revision, err)
+	}
+	return rev, nil
+}
+
+func (r *PostgresRevisionRepository) ListRevisions(animalID int64, afterRevision int64, limit int) (RevisionPage, error) {
+	var rows []revisionRow
+	err := r.db.Select(&rows, `SELECT `+revisionColumns+` FROM animal_revisions
+		WHERE animal_id = $1 AND revision > $2 ORDER BY revision LIMIT $3`,
+		animalID, afterRevision, limit+1)
+	if err != nil {
+		return RevisionPage{}, fmt.Errorf("ListRevisions query error: %w", err)
+	}
+	if len(rows) == 0 && afterRevision == 0 {
+		return RevisionPage{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, animalID)
+	}
+
+	page := RevisionPage{Revisions: make([]AnimalRevision, 0, len(rows))}
+	if len(rows) > limit {
+		rows, page.HasMore = rows[:limit], true
+	}
+	for _, row := range rows {
+		rev, err := row.revision()
+		if err != nil {
+			return RevisionPage{}, err
+		}
+		page.Revisions = append(page.Revisions, rev)
+	}
+	return page, nil
+}
+
+func (r *PostgresRevisionRepository) GetRevision(animalID int64, revision int64) (AnimalRevision, error) {
+	var row revisionRow
+	err := r.db.Get(&row, `SELECT `+revisionColumns+` FROM animal_revisions WHERE animal_id = $1 AND revision = $2`,
+		animalID, revision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AnimalRevision{}, fmt.Errorf("%w: animal %d has no revision %d", ErrRevisionNotFound, animalID, revision)
+		}
+		return AnimalRevision{}, fmt.Errorf("error getting revision: %w", err)
+	}
+	return row.revision()
+}
@@ -0,0 +1,223 @@
+package animal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// animalFields are the JSON names of the fields of Animal, in the order they are
+// rendered. Each is read from the column of the same name.
+var animalFields = func() []string {
+	t := reflect.TypeOf(Animal{})
+	names := make([]string, t.NumField())
+	for i := range t.NumField() {
+		names[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return names
+}()
+
+type includeKey struct{ name, key string }
+
+// includeKeys maps the resources accepted by the include query parameter, in
+// the order they are rendered, to the field of the animal that refers to them.
+var includeKeys = []includeKey{
+	{IncludeSpecies, "species_id"},
+	{IncludeSire, "sire_id"},
+	{IncludeDam, "dam_id"},
+	{IncludeEnclosure, "enclosure_id"},
+	{IncludeTags, "id"},
+}
+
+// parseProjection reads the fields and include parameters of a read of
+// animals. Both take a comma separated list; repeated names are ignored.
+func parseProjection(query url.Values) (Projection, error) {
+	var p Projection
+	if query.Has("fields") {
+		p.Fields = []string{}
+		for _, name := range strings.Split(query.Get("fields"), ",") {
+			if !slices.Contains(animalFields, name) {
+				return Projection{}, badRequest("unknown field %q in fields", name)
+			}
+			if !slices.Contains(p.Fields, name) {
+				p.Fields = append(p.Fields, name)
+			}
+		}
+	}
+	if query.Has("include") {
+		if query.Has("as_of") {
+			return Projection{}, badRequest("include cannot be combined with as_of")
+		}
+		for _, name := range strings.Split(query.Get("include"), ",") {
+			if !slices.ContainsFunc(includeKeys, func(k includeKey) bool { return k.name == name }) {
+				return Projection{}, badRequest("unknown resource %q in include", name)
+			}
+			if !slices.Contains(p.Include, name) {
+				p.Include = append(p.Include, name)
+			}
+		}
+	}
+	return p, nil
+}
+
+// empty reports whether the projection leaves animals as they are.
+func (p Projection) empty() bool {
+	return p.Fields == nil && len(p.Include) == 0
+}
+
+// members returns the members of the animals a projection renders: the
+// requested fields, or all of them, then the included resources.
+func (p Projection) members() []string {
+	var members []string
+	for _, f := range animalFields {
+		if p.Fields == nil || slices.Contains(p.Fields, f) {
+			members = append(members, f)
+		}
+	}
+	for _, k := range includeKeys {
+		if slices.Contains(p.Include, k.name) {
+			members = append(members, k.name)
+		}
+	}
+	return members
+}
+
+// columns returns the fields a projection reads: the requested ones and those
+// listed on Projection that responses and includes depend on, in the order of
+// animalFields.
+func (p Projection) columns(sort []SortField) []string {
+	need := map[string]bool{"id": true, "version": true, "updated_at": true}
+	for _, f := range p.Fields {
+		need[f] = true
+	}
+	for _, f := range sort {
+		need[f.Field] = true
+		if f.Field == "age" {
+			// the cursor of a sort by age holds the birth date
+			need["birth_date"] = true
+		}
+	}
+	for _, k := range includeKeys {
+		if slices.Contains(p.Include, k.name) {
+			need[k.key] = true
+		}
+	}
+
+	var columns []string
+	for _, f := range animalFields {
+		if need[f] {
+			columns = append(columns, f)
+		}
+	}
+	return columns
+}
+
+// projectedAnimal renders the fields of an animal a projection asks for,
+// followed by the resources it includes.
+type projectedAnimal struct {
+	animal     Animal
+	projection Projection
+	related    *RelatedResources
+}
+
+// projectedAnimalList is AnimalListResponse for projected animals.
+type projectedAnimalList struct {
+	Data       []projectedAnimal `json:"data"`
+	NextCursor *string           `json:"next_cursor"`
+}
+
+// projectAnimals applies a projection to animals, loading the resources it
+// includes for all of them at once.
+func projectAnimals(repo AnimalRepository, p Projection, animals []Animal) ([]projectedAnimal, error) {
+	related := &RelatedResources{}
+	if len(p.Include) > 0 {
+		loaded, err := repo.Related(animals, p.Include)
+		if err != nil {
+			return nil, err
+		}
+		related = &loaded
+	}
+
+	projected := make([]projectedAnimal, len(animals))
+	for i, a := range animals {
+		projected[i] = projectedAnimal{animal: a, projection: p, related: related}
+	}
+	return projected, nil
+}
+
+func (v projectedAnimal) MarshalJSON() ([]byte, error) {
+	full, err := json.Marshal(v.animal)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(full, &members); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	write := func(name string, value json.RawMessage) {
+		if buf.Len() > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	for _, name := range animalFields {
+		// deleted_at is omitted when empty, like in the full representation
+		if value, ok := members[name]; ok && (v.projection.Fields == nil || slices.Contains(v.projection.Fields, name)) {
+			write(name, value)
+		}
+	}
+	for _, k := range includeKeys {
+		if !slices.Contains(v.projection.Include, k.name) {
+			continue
+		}
+		value, err := json.Marshal(v.related.embedded(k.name, v.animal))
+		if err != nil {
+			return nil, err
+		}
+		write(k.name, value)
+	}
+
+	return append(append([]byte{'{'}, buf.Bytes()...), '}'), nil
+}
+
+// embedded returns the resource of the given kind related to an animal: the
+// resource itself or nil, and for tags a possibly empty list of names.
+func (r *RelatedResources) embedded(name string, a Animal) any {
+	switch name {
+	case IncludeSpecies:
+		return relatedByID(r.Species, a.SpeciesID)
+	case IncludeSire:
+		return relatedByID(r.Parents, a.SireID)
+	case IncludeDam:
+		return relatedByID(r.Parents, a.DamID)
+	case IncludeEnclosure:
+		return relatedByID(r.Enclosures, a.EnclosureID)
+	case IncludeTags:
+		if tags, ok := r.Tags[a.ID]; ok {
+			return tags
+		}
+		return []string{}
+	}
+	return nil
+}
+
+// relatedByID returns the resource with the given id, or nil when there is no
+// id or the resource was not found.
+func relatedByID[T any](resources map[int64]T, id *int64) any {
+	if id == nil {
+		return nil
+	}
+	if v, ok := resources[*id]; ok {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package animal
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Related loads every resource of one kind with a single query on the ids the
+// animals refer to. Parents are read as they are now; soft deleted ones are
+// left out like on GET /animals/:id.
+func (r *PostgresAnimalRepository) Related(animals []Animal, include []string) (RelatedResources, error) {
+	var (
+		related RelatedResources
+		err     error
+	)
+
+	if slices.Contains(include, IncludeSpecies) {
+		ids := relatedIDs(animals, func(a Animal) *int64 { return a.SpeciesID })
+		related.Species, err = selectByID(r.db, `SELECT `+speciesColumns+` FROM species WHERE id = ANY($1)`, ids,
+			func(s Species) int64 { return s.ID })
+		if err != nil {
+			return RelatedResources{}, fmt.Errorf("error loading species: %w", err)
+		}
+	}
+
+	sires, dams := slices.Contains(include, IncludeSire), slices.Contains(include, IncludeDam)
+	if sires || dams {
+		var ids []int64
+		if sires {
+			ids = relatedIDs(animals, func(a Animal) *int64 { return a.SireID })
+		}
+		if dams {
+			ids = append(ids, relatedIDs(animals, func(a Animal) *int64 { return a.DamID })...)
+		}
+		related.Parents, err = selectByID(r.db, `SELECT `+animalColumns+` FROM `+liveAnimals+` WHERE id = ANY($1) AND deleted_at IS NULL`, ids,
+			func(a Animal) int64 { return a.ID })
+		if err != nil {
+			return RelatedResources{}, fmt.Errorf("error loading parents: %w", err)
+		}
+	}
+
+	if slices.Contains(include, IncludeEnclosure) {
+		ids := relatedIDs(animals, func(a Animal) *int64 { return a.EnclosureID })
+		related.Enclosures, err = selectByID(r.db, `SELECT `+enclosureColumns+` FROM enclosures WHERE id = ANY($1)`, ids,
+			func(e Enclosure) int64 { return e.ID })
+		if err != nil {
+			return RelatedResources{}, fmt.Errorf("error loading enclosures: %w", err)
+		}
+	}
+
+	if slices.Contains(include, IncludeTags) {
+		related.Tags, err = tagsOfAnimals(r.db, animals)
+		if err != nil {
+			return RelatedResources{}, err
+		}
+	}
+
+	return related, nil
+}
+
+// relatedIDs collects the distinct, non-nil ids key returns for the animals.
+func relatedIDs(animals []Animal, key func(Animal) *int64) []int64 {
+	var ids []int64
+	for _, a := range animals {
+		if id := key(a); id != nil && !slices.Contains(ids, *id) {
+			ids = append(ids, *id)
+		}
+	}
+	return ids
+}
+
+// selectByID runs a query that takes an array of ids as $1 and returns its
+// rows keyed by id. No query is made without ids.
+func selectByID[T any](q sqlx.Queryer, query string, ids []int64, id func(T) int64) (map[int64]T, error) {
+	var rows []T
+	if len(ids) > 0 {
+		if err := sqlx.Select(q, &rows, query, pq.Array(ids)); err != nil {
+			return nil, err
+		}
+	}
+
+	byID := make(map[int64]T, len(rows))
+	for _, row := range rows {
+		byID[id(row)] = row
+	}
+	return byID, nil
+}
+
+// tagsOfAnimals returns the names of the tags of each animal, in alphabetical
+// order, like animalTags does for one of them.
+func tagsOfAnimals(q sqlx.Queryer, animals []Animal) (map[int64][]string, error) {
+	tags := make(map[int64][]string, len(animals))
+	ids := make([]int64, len(animals))
+	for i, a := range animals {
+		ids[i] = a.ID
+		tags[a.ID] = []string{}
+	}
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	var rows []struct {
+		AnimalID int64  `db:"animal_id"`
+		Name     string `db:"name"`
+	}
+	err := sqlx.Select(q, &rows, `SELECT at.animal_id, t.name FROM animal_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.animal_id = ANY($1) ORDER BY at.animal_id, t.name`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("error loading tags: %w", err)
+	}
+	for _, row := range rows {
+		tags[row.AnimalID] = append(tags[row.AnimalID], row.Name)
+	}
+	return tags, nil
+}
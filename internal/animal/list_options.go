@@ -0,0 +1,282 @@
+package animal
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sortableFields lists the fields accepted by the sort query parameter.
+var sortableFields = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"age":        true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// filterOperators lists, per field, the filter query parameters that are accepted.
+// A parameter is named after its field, optionally followed by "_<operator>".
+var filterOperators = map[string][]string{
+	"name":        {"name", "name_prefix", "name_contains"},
+	"age":         {"age_gte", "age_lte"},
+	"description": {"description_contains"},
+	"updated":     {"updated_since"},
+	"taxon":       {"taxon"},
+	"tag":         {"tag", "tag_match"},
+	"status":      {"status"},
+}
+
+// repeatableParams are the query parameters that may be given more than once.
+var repeatableParams = map[string]bool{
+	"tag":    true,
+	"status": true,
+}
+
+// pagingParams are the query parameters handled by parsePageParams.
+var pagingParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+	"sort":   true,
+}
+
+// readParams are the query parameters accepted by every read of animals.
+var readParams = map[string]bool{
+	"as_of":   true,
+	"fields":  true,
+	"include": true,
+}
+
+// adminListParams are the query parameters only accepted on the admin list.
+var adminListParams = map[string]bool{
+	"include_deleted": true,
+}
+
+// parseListOptions turns the query string of GET /animals into ListOptions.
+// The admin list additionally accepts adminListParams.
+func parseListOptions(ctx *gin.Context, admin bool) (ListOptions, error) {
+	query := ctx.Request.URL.Query()
+	if err := checkListParams(query, admin); err != nil {
+		return ListOptions{}, err
+	}
+
+	sort, err := parseSort(query.Get("sort"))
+	if err != nil {
+		return ListOptions{}, err
+	}
+
+	limit, after, err := parsePageParams(ctx, sort)
+	if err != nil {
+		return ListOptions{}, err
+	}
+
+	opts := ListOptions{Limit: limit, After: after, Sort: sort}
+
+	nameFilters := 0
+	for param, match := range map[string]StringMatch{"name": MatchExact, "name_prefix": MatchPrefix, "name_contains": MatchContains} {
+		if query.Has(param) {
+			opts.Name = &StringFilter{Match: match, Value: query.Get(param)}
+			nameFilters++
+		}
+	}
+	if nameFilters > 1 {
+		return ListOptions{}, badRequest("only one of name, name_prefix and name_contains may be given")
+	}
+
+	if opts.AgeGTE, err = intParam(query, "age_gte"); err != nil {
+		return ListOptions{}, err
+	}
+	if opts.AgeLTE, err = intParam(query, "age_lte"); err != nil {
+		return ListOptions{}, err
+	}
+	if opts.AgeGTE != nil && opts.AgeLTE != nil && *opts.AgeGTE > *opts.AgeLTE {
+		return ListOptions{}, badRequest("age_gte must not be greater than age_lte")
+	}
+
+	if query.Has("description_contains") {
+		v := query.Get("description_contains")
+		opts.DescriptionContains = &v
+	}
+
+	if query.Has("include_deleted") {
+		include, err := strconv.ParseBool(query.Get("include_deleted"))
+		if err != nil {
+			return ListOptions{}, badRequest("include_deleted must be true or false")
+		}
+		opts.IncludeDeleted = include
+	}
+
+	if query.Has("updated_since") {
+		since, err := time.Parse(time.RFC3339, query.Get("updated_since"))
+		if err != nil {
+			return ListOptions{}, badRequest("updated_since must be an RFC 3339 timestamp")
+		}
+		opts.UpdatedSince = &since
+	}
+
+	if query.Has("taxon") {
+		taxon, err := strconv.ParseInt(query.Get("taxon"), 10, 64)
+		if err != nil {
+			return ListOptions{}, badRequest("taxon must be a species id")
+		}
+		opts.Taxon = &taxon
+	}
+
+	if opts.Tags, opts.TagMatch, err = parseTagFilter(query); err != nil {
+		return ListOptions{}, err
+	}
+
+	for _, status := range query["status"] {
+		if !slices.Contains(Statuses, status) {
+			return ListOptions{}, badRequest("status must be one of %s", strings.Join(Statuses, ", "))
+		}
+		if !slices.Contains(opts.Statuses, status) {
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+
+	if opts.AsOf, err = parseAsOf(query); err != nil {
+		return ListOptions{}, err
+	}
+
+	if opts.Projection, err = parseProjection(query); err != nil {
+		return ListOptions{}, err
+	}
+
+	return opts, nil
+}
+
+// parseTagFilter reads the repeated tag parameter and tag_match, which picks
+// whether animals need all of the tags (the default) or any of them.
+func parseTagFilter(query url.Values) ([]string, TagMatch, error) {
+	var tags []string
+	for _, v := range query["tag"] {
+		tag := normalizeTag(v)
+		if !validTag(tag) {
+			return nil, "", badRequest("tag %q is not a valid tag", v)
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	match := TagMatchAll
+	if query.Has("tag_match") {
+		match = TagMatch(query.Get("tag_match"))
+		if match != TagMatchAll && match != TagMatchAny {
+			return nil, "", badRequest("tag_match must be any or all")
+		}
+		if tags == nil {
+			return nil, "", badRequest("tag_match needs at least one tag")
+		}
+	}
+	return tags, match, nil
+}
+
+// parseAsOf reads the as_of parameter of point-in-time reads.
+func parseAsOf(query url.Values) (*time.Time, error) {
+	if !query.Has("as_of") {
+		return nil, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, query.Get("as_of"))
+	if err != nil {
+		return nil, badRequest("as_of must be an RFC 3339 timestamp")
+	}
+	return &asOf, nil
+}
+
+// checkListParams rejects query parameters that name an unknown field or operator.
+func checkListParams(query url.Values, admin bool) error {
+	known := make(map[string]bool, len(pagingParams)+len(readParams))
+	for p := range pagingParams {
+		known[p] = true
+	}
+	for p := range readParams {
+		known[p] = true
+	}
+	if admin {
+		for p := range adminListParams {
+			known[p] = true
+		}
+	}
+	for _, params := range filterOperators {
+		for _, p := range params {
+			known[p] = true
+		}
+	}
+
+	for param, values := range query {
+		if !known[param] {
+			field, op, _ := strings.Cut(param, "_")
+			if _, ok := filterOperators[field]; ok {
+				if op == "" {
+					return badRequest("field %q cannot be filtered without an operator", field)
+				}
+				return badRequest("unsupported operator %q for field %q", op, field)
+			}
+			return badRequest("unknown query parameter %q", param)
+		}
+		if len(values) > 1 && !repeatableParams[param] {
+			return badRequest("query parameter %q must be given only once", param)
+		}
+	}
+	return nil
+}
+
+// parseSort parses a comma separated sort list such as "-age,name".
+// A leading "-" sorts the field in descending order.
+func parseSort(value string) ([]SortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var (
+		sort []SortField
+		seen = make(map[string]bool)
+	)
+	for _, part := range strings.Split(value, ",") {
+		field := SortField{Field: strings.TrimSpace(part)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Field, field.Desc = field.Field[1:], true
+		} else {
+			field.Field = strings.TrimPrefix(field.Field, "+")
+		}
+
+		if !sortableFields[field.Field] {
+			return nil, badRequest("cannot sort by %q", field.Field)
+		}
+		if seen[field.Field] {
+			return nil, badRequest("sort field %q given more than once", field.Field)
+		}
+		seen[field.Field] = true
+		sort = append(sort, field)
+	}
+	return sort, nil
+}
+
+// sortKey is the canonical string form of a sort order, used to tie cursors to it.
+func sortKey(sort []SortField) string {
+	parts := make([]string, len(sort))
+	for i, f := range sort {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func intParam(query url.Values, name string) (*int, error) {
+	if !query.Has(name) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(query.Get(name))
+	if err != nil {
+		return nil, badRequest("%s must be an integer", name)
+	}
+	return &n, nil
+}
@@ -0,0 +1,253 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// medicalRecordListParams are the query parameters accepted by GET /animals/:id/medical-records.
+var medicalRecordListParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+	"kind":   true,
+}
+
+// dueVaccinationParams are the query parameters accepted by GET /vaccinations/due.
+var dueVaccinationParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+	"before": true,
+}
+
+// The orders of medical record pages, which their cursors are issued for.
+var (
+	byAdministeredOn = []SortField{{Field: "administered_on"}}
+	byDueOn          = []SortField{{Field: "due_on"}}
+)
+
+type MedicalRecordHandler struct {
+	module  Module
+	repo    MedicalRecordRepository
+	animals AnimalRepository
+}
+
+func NewMedicalRecordHandler(module Module, repo MedicalRecordRepository, animals AnimalRepository) *MedicalRecordHandler {
+	return &MedicalRecordHandler{module: module, repo: repo, animals: animals}
+}
+
+// bindMedicalRecord reads the body of a create or update request.
+func bindMedicalRecord(ctx *gin.Context) (MedicalRecordRequest, error) {
+	var req MedicalRecordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return MedicalRecordRequest{}, bindingError(err)
+	}
+	// dates in DateLayout sort like strings
+	if req.DueOn != nil && *req.DueOn < req.AdministeredOn {
+		return MedicalRecordRequest{}, ErrDueBeforeAdministered
+	}
+	return req, nil
+}
+
+func (h *MedicalRecordHandler) CreateMedicalRecordHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	req, err := bindMedicalRecord(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	record, err := h.repo.CreateMedicalRecord(id, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/animals/%d/medical-records/%d", id, record.ID))
+	ctx.JSON(http.StatusCreated, record)
+}
+
+func (h *MedicalRecordHandler) ListMedicalRecordsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	query := ctx.Request.URL.Query()
+	for param := range query {
+		if !medicalRecordListParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parseRecordPage(ctx, byAdministeredOn)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts := MedicalRecordListOptions{Limit: limit, After: after}
+
+	if query.Has("kind") {
+		kind := query.Get("kind")
+		if !slices.Contains(MedicalRecordKinds, kind) {
+			h.respondError(ctx, badRequest("kind must be one of %v", MedicalRecordKinds))
+			return
+		}
+		opts.Kind = &kind
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	page, err := h.repo.ListMedicalRecords(id, opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	writeRecordPage(ctx, limit, page, byAdministeredOn, func(r MedicalRecord) Date { return r.AdministeredOn })
+}
+
+func (h *MedicalRecordHandler) GetMedicalRecordHandler(ctx *gin.Context) {
+	id, recordID, err := parseRecordID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	record, err := h.repo.GetMedicalRecord(id, recordID)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func (h *MedicalRecordHandler) UpdateMedicalRecordHandler(ctx *gin.Context) {
+	id, recordID, err := parseRecordID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	req, err := bindMedicalRecord(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	record, err := h.repo.UpdateMedicalRecord(id, recordID, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+func (h *MedicalRecordHandler) DeleteMedicalRecordHandler(ctx *gin.Context) {
+	id, recordID, err := parseRecordID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteMedicalRecord(id, recordID); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Medical record deleted successfully"})
+}
+
+// DueVaccinationsHandler lists the vaccinations of all animals that are due
+// before the given day, by default today, so overdue ones come first. Pass a
+// day in the future to include upcoming vaccinations as well.
+func (h *MedicalRecordHandler) DueVaccinationsHandler(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	for param := range query {
+		if !dueVaccinationParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parseRecordPage(ctx, byDueOn)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts := DueVaccinationOptions{Limit: limit, After: after, Before: Date{time.Now().UTC().Truncate(24 * time.Hour)}}
+
+	if query.Has("before") {
+		before, err := ParseDate(query.Get("before"))
+		if err != nil {
+			h.respondError(ctx, badRequest("before must be a date formatted as %s", DateLayout))
+			return
+		}
+		opts.Before = before
+	}
+
+	page, err := h.repo.DueVaccinations(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	writeRecordPage(ctx, limit, page, byDueOn, func(r MedicalRecord) Date { return *r.DueOn })
+}
+
+// parseRecordID reads the ids of the animal and of one of its medical records.
+func parseRecordID(ctx *gin.Context) (int64, int64, error) {
+	id, err := parseID(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	recordID, err := strconv.ParseInt(ctx.Param("record_id"), 10, 64)
+	if err != nil {
+		return 0, 0, badRequest("medical record id must be an integer")
+	}
+	return id, recordID, nil
+}
+
+// parseRecordPage reads the limit and cursor of a page of medical records in
+// the given order.
+func parseRecordPage(ctx *gin.Context, sort []SortField) (int, *MedicalRecordCursor, error) {
+	limit, after, err := parsePageParams(ctx, sort)
+	if err != nil || after == nil {
+		return limit, nil, err
+	}
+	s, _ := after.Values[0].(string)
+	date, err := ParseDate(s)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return limit, &MedicalRecordCursor{Date: date, ID: after.ID}, nil
+}
+
+// writeRecordPage writes a page of medical records ordered by the date key returns.
+func writeRecordPage(ctx *gin.Context, limit int, page MedicalRecordPage, sort []SortField, key func(MedicalRecord) Date) {
+	var nextCursor *string
+	if page.HasMore {
+		last := page.Records[len(page.Records)-1]
+		token := EncodeCursor(Cursor{ID: last.ID, Sort: sortKey(sort), Values: []any{key(last).String()}})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, MedicalRecordListResponse{Data: page.Records, NextCursor: nextCursor})
+}
@@ -0,0 +1,159 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// medicalRecordColumns is the select list that matches the MedicalRecord struct.
+const medicalRecordColumns = `id, animal_id, kind, title, notes, administered_on, due_on, created_at, updated_at`
+
+// MedicalRecordRepository keeps the health history of animals. Records of a soft
+// deleted animal are hidden, and purging the animal removes them.
+type MedicalRecordRepository interface {
+	// CreateMedicalRecord fails with ErrAnimalNotFound when the animal does not exist.
+	CreateMedicalRecord(animalID int64, r MedicalRecordRequest) (MedicalRecord, error)
+	ListMedicalRecords(animalID int64, opts MedicalRecordListOptions) (MedicalRecordPage, error)
+	GetMedicalRecord(animalID, id int64) (MedicalRecord, error)
+	UpdateMedicalRecord(animalID, id int64, r MedicalRecordRequest) (MedicalRecord, error)
+	DeleteMedicalRecord(animalID, id int64) error
+	// DueVaccinations lists the vaccinations of all animals that are due before
+	// opts.Before. A vaccination is no longer due once the animal got a later
+	// dose of the same vaccine, that is a vaccination with the same title.
+	DueVaccinations(opts DueVaccinationOptions) (MedicalRecordPage, error)
+}
+
+type PostgresMedicalRecordRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresMedicalRecordRepository(db *sqlx.DB) *PostgresMedicalRecordRepository {
+	return &PostgresMedicalRecordRepository{db: db}
+}
+
+// liveAnimal restricts a query on medical_records aliased m to animals that are not deleted.
+const liveAnimal = `EXISTS (SELECT 1 FROM animals a WHERE a.id = m.animal_id AND a.deleted_at IS NULL)`
+
+func (r *PostgresMedicalRecordRepository) CreateMedicalRecord(animalID int64, req MedicalRecordRequest) (MedicalRecord, error) {
+	var record MedicalRecord
+	err := r.db.QueryRowx(`INSERT INTO medical_records (animal_id, kind, title, notes, administered_on, due_on)
+		SELECT id, $2, $3, $4, $5::date, $6::date FROM animals WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+medicalRecordColumns,
+		animalID, req.Kind, req.Title, req.Notes, req.AdministeredOn, req.DueOn).StructScan(&record)
+	if err != nil {
+		// a purge between the select and the insert trips the foreign key instead
+		if errors.Is(err, sql.ErrNoRows) || isViolation(err, foreignKeyViolation) {
+			return MedicalRecord{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, animalID)
+		}
+		return MedicalRecord{}, medicalRecordError(err, "failed to insert medical record")
+	}
+	return record, nil
+}
+
+func (r *PostgresMedicalRecordRepository) ListMedicalRecords(animalID int64, opts MedicalRecordListOptions) (MedicalRecordPage, error) {
+	var (
+		where = []string{"m.animal_id = $1"}
+		args  = []any{animalID}
+	)
+	if opts.Kind != nil {
+		args = append(args, *opts.Kind)
+		where = append(where, fmt.Sprintf("m.kind = $%d", len(args)))
+	}
+	if opts.After != nil {
+		args = append(args, opts.After.Date, opts.After.ID)
+		where = append(where, fmt.Sprintf("(m.administered_on, m.id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, opts.Limit+1)
+
+	return r.page(`SELECT `+medicalRecordColumns+` FROM medical_records m WHERE `+strings.Join(where, " AND ")+
+		fmt.Sprintf(` ORDER BY m.administered_on, m.id LIMIT $%d`, len(args)), args, opts.Limit)
+}
+
+func (r *PostgresMedicalRecordRepository) DueVaccinations(opts DueVaccinationOptions) (MedicalRecordPage, error) {
+	var (
+		where = []string{"m.kind = 'vaccination'", "m.due_on < $1", liveAnimal,
+			`NOT EXISTS (SELECT 1 FROM medical_records l WHERE l.kind = 'vaccination'
+				AND l.animal_id = m.animal_id AND lower(l.title) = lower(m.title)
+				AND (l.administered_on, l.id) > (m.administered_on, m.id))`}
+		args = []any{opts.Before}
+	)
+	if opts.After != nil {
+		args = append(args, opts.After.Date, opts.After.ID)
+		where = append(where, fmt.Sprintf("(m.due_on, m.id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, opts.Limit+1)
+
+	return r.page(`SELECT `+medicalRecordColumns+` FROM medical_records m WHERE `+strings.Join(where, " AND ")+
+		fmt.Sprintf(` ORDER BY m.due_on, m.id LIMIT $%d`, len(args)), args, opts.Limit)
+}
+
+// page runs a list query that selects one row more than limit, to find out
+// whether another page follows.
+func (r *PostgresMedicalRecordRepository) page(query string, args []any, limit int) (MedicalRecordPage, error) {
+	records := []MedicalRecord{}
+	if err := r.db.Select(&records, query, args...); err != nil {
+		return MedicalRecordPage{}, fmt.Errorf("medical records query error: %w", err)
+	}
+
+	page := MedicalRecordPage{Records: records}
+	if len(records) > limit {
+		page.Records, page.HasMore = records[:limit], true
+	}
+	return page, nil
+}
+
+func (r *PostgresMedicalRecordRepository) GetMedicalRecord(animalID, id int64) (MedicalRecord, error) {
+	var record MedicalRecord
+	err := r.db.Get(&record, `SELECT `+medicalRecordColumns+` FROM medical_records m
+		WHERE m.id = $1 AND m.animal_id = $2 AND `+liveAnimal, id, animalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MedicalRecord{}, fmt.Errorf("%w: id=%d", ErrMedicalRecordNotFound, id)
+		}
+		return MedicalRecord{}, fmt.Errorf("error getting medical record: %w", err)
+	}
+	return record, nil
+}
+
+func (r *PostgresMedicalRecordRepository) UpdateMedicalRecord(animalID, id int64, req MedicalRecordRequest) (MedicalRecord, error) {
+	var record MedicalRecord
+	err := r.db.QueryRowx(`UPDATE medical_records m
+		SET kind = $3, title = $4, notes = $5, administered_on = $6, due_on = $7, updated_at = now()
+		WHERE m.id = $1 AND m.animal_id = $2 AND `+liveAnimal+`
+		RETURNING `+medicalRecordColumns,
+		id, animalID, req.Kind, req.Title, req.Notes, req.AdministeredOn, req.DueOn).StructScan(&record)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MedicalRecord{}, fmt.Errorf("%w: id=%d", ErrMedicalRecordNotFound, id)
+		}
+		return MedicalRecord{}, medicalRecordError(err, "failed to update medical record")
+	}
+	return record, nil
+}
+
+func (r *PostgresMedicalRecordRepository) DeleteMedicalRecord(animalID, id int64) error {
+	res, err := r.db.Exec(`DELETE FROM medical_records m
+		WHERE m.id = $1 AND m.animal_id = $2 AND `+liveAnimal, id, animalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete medical record: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrMedicalRecordNotFound, id)
+	}
+	return nil
+}
+
+func medicalRecordError(err error, message string) error {
+	if isViolation(err, checkViolation) {
+		return ErrDueBeforeAdministered
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -1,20 +1,563 @@
 package animal
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Animal is an animal of the shelter. Age is not stored but computed from
+// BirthDate when the animal is read; BirthDateEstimated tells that the birth
+// date is a guess, such as one derived from an age.
 type Animal struct {
-	ID          int64  `db:"id" json:"id"`
-	Name        string `db:"name" json:"name"`
-	Age         int    `db:"age" json:"age"`
-	Description string `db:"description" json:"description"`
+	ID                 int64      `db:"id" json:"id"`
+	Name               string     `db:"name" json:"name"`
+	Age                int        `db:"age" json:"age"`
+	BirthDate          Date       `db:"birth_date" json:"birth_date"`
+	BirthDateEstimated bool       `db:"birth_date_estimated" json:"birth_date_estimated"`
+	Description        string     `db:"description" json:"description"`
+	SpeciesID          *int64     `db:"species_id" json:"species_id"`
+	SireID             *int64     `db:"sire_id" json:"sire_id"`
+	DamID              *int64     `db:"dam_id" json:"dam_id"`
+	Status             string     `db:"status" json:"status"`
+	EnclosureID        *int64     `db:"enclosure_id" json:"enclosure_id"`
+	Version            int64      `db:"version" json:"version"`
+	CreatedAt          time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt          time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt          *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
+// AnimalCreateRequest gives either the birth date of the animal or its age, from
+// which an estimated birth date is derived. Without either the animal is taken
+// to be born today.
 type AnimalCreateRequest struct {
-	Name        string
-	Age         int
-	Description string
+	Name               string  `json:"name" binding:"required,max=100"`
+	Age                *int    `json:"age" binding:"omitempty,min=0,max=200,excluded_with=BirthDate"`
+	BirthDate          *string `json:"birth_date" binding:"omitempty,datetime=2006-01-02,notfuture"`
+	BirthDateEstimated bool    `json:"birth_date_estimated"`
+	Description        string  `json:"description" binding:"max=2000"`
+	SpeciesID          *int64  `json:"species_id" binding:"omitempty,min=1"`
+	SireID             *int64  `json:"sire_id" binding:"omitempty,min=1"`
+	DamID              *int64  `json:"dam_id" binding:"omitempty,min=1"`
 }
 
+// AnimalUpdateRequest takes the birth date or the age like AnimalCreateRequest.
 type AnimalUpdateRequest struct {
-	Name        string
-	Age         int
-	Description string
+	Name               string  `json:"name" binding:"required,max=100"`
+	Age                *int    `json:"age" binding:"omitempty,min=0,max=200,excluded_with=BirthDate"`
+	BirthDate          *string `json:"birth_date" binding:"omitempty,datetime=2006-01-02,notfuture"`
+	BirthDateEstimated bool    `json:"birth_date_estimated"`
+	Description        string  `json:"description" binding:"max=2000"`
+	SpeciesID          *int64  `json:"species_id" binding:"omitempty,min=1"`
+	SireID             *int64  `json:"sire_id" binding:"omitempty,min=1"`
+	DamID              *int64  `json:"dam_id" binding:"omitempty,min=1"`
+}
+
+// AnimalFields holds an optional value per writable animal field. Nil means absent.
+// A SpeciesID, SireID or DamID of 0 stands for none. Setting Age replaces the
+// birth date with one estimated from it.
+type AnimalFields struct {
+	Name               *string
+	Age                *int
+	BirthDate          *string
+	BirthDateEstimated *bool
+	Description        *string
+	SpeciesID          *int64
+	SireID             *int64
+	DamID              *int64
+}
+
+// AnimalPatch is a partial update of an animal. Fields set in Set are written,
+// everything else is kept. Fields set in Test must equal the stored values,
+// otherwise nothing is written.
+type AnimalPatch struct {
+	Set  AnimalFields
+	Test AnimalFields
+}
+
+// StringMatch is the comparison applied by a StringFilter.
+type StringMatch string
+
+const (
+	MatchExact    StringMatch = "exact"
+	MatchPrefix   StringMatch = "prefix"
+	MatchContains StringMatch = "contains"
+)
+
+// TagMatch tells whether a list filtered by several tags needs any or all of them.
+type TagMatch string
+
+const (
+	TagMatchAll TagMatch = "all"
+	TagMatchAny TagMatch = "any"
+)
+
+// StringFilter matches a text column. Prefix and contains matches are case-insensitive.
+type StringFilter struct {
+	Match StringMatch
+	Value string
+}
+
+// SortField orders a list by one column. Field is one of the sortable field names.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions describes which slice of the animals table a list call should return.
+// Nil filters are not applied. Results are always ordered by Sort followed by id.
+// AsOf lists the animals as they were at that instant instead of now.
+// Taxon keeps the animals whose species is that taxon or any taxon below it.
+// Tags keeps the animals that carry any of the tags, or all of them with TagMatch
+// set to TagMatchAll. Owner keeps the animals that owner adopted and has not returned.
+// Statuses keeps the animals in any of the statuses. Enclosure keeps the animals
+// housed in that enclosure.
+type ListOptions struct {
+	Limit               int
+	After               *Cursor
+	Name                *StringFilter
+	AgeGTE              *int
+	AgeLTE              *int
+	DescriptionContains *string
+	UpdatedSince        *time.Time
+	IncludeDeleted      bool
+	AsOf                *time.Time
+	Taxon               *int64
+	Tags                []string
+	TagMatch            TagMatch
+	Owner               *int64
+	Statuses            []string
+	Enclosure           *int64
+	Sort                []SortField
+	Projection          Projection
+}
+
+// Projection narrows a read of animals down to some of their fields and names
+// the related resources embedded in each of them. Fields is nil for every
+// field. The repository always reads id, version and updated_at, the sort
+// fields and the keys of the included resources, so the response can still
+// be paginated, validated and joined.
+type Projection struct {
+	Fields  []string
+	Include []string
+}
+
+// Resources that can be embedded in an animal with the include query parameter.
+const (
+	IncludeSpecies   = "species"
+	IncludeSire      = "sire"
+	IncludeDam       = "dam"
+	IncludeEnclosure = "enclosure"
+	IncludeTags      = "tags"
+)
+
+// RelatedResources holds the resources included in a read of animals, keyed by
+// their id. Sires and dams share Parents; tags are keyed by the animal.
+type RelatedResources struct {
+	Species    map[int64]Species
+	Parents    map[int64]Animal
+	Enclosures map[int64]Enclosure
+	Tags       map[int64][]string
+}
+
+// AnimalPage is a single page of animals ordered by the pagination key.
+// HasMore reports whether at least one more row exists after the last item.
+type AnimalPage struct {
+	Animals []Animal
+	HasMore bool
+}
+
+// AnimalListResponse is the envelope returned by GET /animals.
+type AnimalListResponse struct {
+	Data       []Animal `json:"data"`
+	NextCursor *string  `json:"next_cursor"`
+}
+
+// Revision operations, one for each kind of write on an animal.
+const (
+	OpCreate     = "create"
+	OpUpdate     = "update"
+	OpDelete     = "delete"
+	OpRestore    = "restore"
+	OpPurge      = "purge"
+	OpTransition = "transition"
+	OpMove       = "move"
+)
+
+// AnimalRevision is the full state of an animal right after a write. Revision
+// equals the version the write produced; a purge gets the next one.
+type AnimalRevision struct {
+	AnimalID  int64     `json:"animal_id"`
+	Revision  int64     `json:"revision"`
+	Operation string    `json:"operation"`
+	Snapshot  Animal    `json:"snapshot"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// RevisionPage is a single page of revisions ordered by revision.
+type RevisionPage struct {
+	Revisions []AnimalRevision
+	HasMore   bool
+}
+
+// RevisionListResponse is the envelope returned by GET /animals/:id/history.
+type RevisionListResponse struct {
+	Data       []AnimalRevision `json:"data"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+// FieldChange is one field that differs between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// RevisionDiff is the response of GET /animals/:id/history/diff.
+type RevisionDiff struct {
+	AnimalID int64         `json:"animal_id"`
+	From     int64         `json:"from"`
+	To       int64         `json:"to"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+// Taxonomic ranks from the top of the hierarchy down.
+var SpeciesRanks = []string{"kingdom", "phylum", "class", "order", "family", "genus", "species"}
+
+// Species is a taxon of any rank. Every taxon but a kingdom has a parent of a higher rank.
+type Species struct {
+	ID             int64          `db:"id" json:"id"`
+	ParentID       *int64         `db:"parent_id" json:"parent_id"`
+	Rank           string         `db:"rank" json:"rank"`
+	ScientificName string         `db:"scientific_name" json:"scientific_name"`
+	CommonNames    pq.StringArray `db:"common_names" json:"common_names"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+type SpeciesRequest struct {
+	ParentID       *int64   `json:"parent_id" binding:"omitempty,min=1"`
+	Rank           string   `json:"rank" binding:"required,oneof=kingdom phylum class order family genus species"`
+	ScientificName string   `json:"scientific_name" binding:"required,max=200"`
+	CommonNames    []string `json:"common_names" binding:"max=20,dive,required,max=100"`
+}
+
+// SpeciesListOptions describes which page of species a list call should return.
+type SpeciesListOptions struct {
+	Limit    int
+	AfterID  int64
+	Rank     *string
+	ParentID *int64
+}
+
+// SpeciesPage is a single page of species ordered by id.
+type SpeciesPage struct {
+	Species []Species
+	HasMore bool
+}
+
+// SpeciesListResponse is the envelope returned by GET /species.
+type SpeciesListResponse struct {
+	Data       []Species `json:"data"`
+	NextCursor *string   `json:"next_cursor"`
+}
+
+// Attachment describes a file, such as a photo or a vet report, attached to an
+// animal. The content itself lives in the blob store under StorageKey.
+type Attachment struct {
+	ID          int64  `db:"id" json:"id"`
+	AnimalID    int64  `db:"animal_id" json:"animal_id"`
+	Filename    string `db:"filename" json:"filename"`
+	ContentType string `db:"content_type" json:"content_type"`
+	Size        int64  `db:"size" json:"size"`
+	// Checksum is the hex encoded SHA-256 of the content.
+	Checksum   string    `db:"checksum" json:"checksum"`
+	StorageKey string    `db:"storage_key" json:"-"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// Tag is a label such as "quarantine" together with the number of animals,
+// not counting deleted ones, that carry it.
+type Tag struct {
+	ID    int64  `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Count int64  `db:"count" json:"count"`
+}
+
+// TagsRequest is the body of POST /animals/:id/tags.
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1,max=20,dive,required,max=50"`
+}
+
+// TagPage is a single page of tags ordered by id.
+type TagPage struct {
+	Tags    []Tag
+	HasMore bool
+}
+
+// TagListResponse is the envelope returned by GET /tags.
+type TagListResponse struct {
+	Data       []Tag   `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Parent relations of a PedigreeEdge.
+const (
+	RelationSire = "sire"
+	RelationDam  = "dam"
+)
+
+// PedigreeNode is an animal of a pedigree graph. Depth counts the generations
+// between it and the root of the graph, 0 being the root itself. A soft deleted
+// animal is Deleted and written with its id and depth only.
+type PedigreeNode struct {
+	Animal
+	Depth   int  `json:"depth"`
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+func (n PedigreeNode) MarshalJSON() ([]byte, error) {
+	if n.Deleted {
+		return json.Marshal(struct {
+			ID      int64 `json:"id"`
+			Depth   int   `json:"depth"`
+			Deleted bool  `json:"deleted"`
+		}{n.ID, n.Depth, true})
+	}
+	type node PedigreeNode
+	return json.Marshal(node(n))
+}
+
+// PedigreeEdge links a parent to its offspring.
+type PedigreeEdge struct {
+	ParentID int64  `db:"parent_id" json:"parent_id"`
+	ChildID  int64  `db:"child_id" json:"child_id"`
+	Relation string `db:"relation" json:"relation"`
+	Depth    int    `db:"depth" json:"-"`
+}
+
+// PedigreeGraph is the response of GET /animals/:id/ancestors and /descendants.
+// Soft deleted animals are kept in it as placeholder nodes, so that the graph
+// has no gaps.
+type PedigreeGraph struct {
+	RootID int64          `json:"root_id"`
+	Depth  int            `json:"depth"`
+	Nodes  []PedigreeNode `json:"nodes"`
+	Edges  []PedigreeEdge `json:"edges"`
+}
+
+// Inbreeding is the response of GET /animals/:id/inbreeding.
+type Inbreeding struct {
+	AnimalID    int64   `json:"animal_id"`
+	Coefficient float64 `json:"coefficient"`
+	// Generations is how many generations of known ancestors went into the coefficient.
+	Generations int `json:"generations"`
+}
+
+// Kinds of medical records.
+var MedicalRecordKinds = []string{"diagnosis", "treatment", "vaccination"}
+
+// MedicalRecord is an entry of the health history of an animal. AdministeredOn
+// is the day of the diagnosis, treatment or vaccination, DueOn the day a
+// follow-up, such as the next dose of a vaccine, is due.
+type MedicalRecord struct {
+	ID             int64     `db:"id" json:"id"`
+	AnimalID       int64     `db:"animal_id" json:"animal_id"`
+	Kind           string    `db:"kind" json:"kind"`
+	Title          string    `db:"title" json:"title"`
+	Notes          string    `db:"notes" json:"notes"`
+	AdministeredOn Date      `db:"administered_on" json:"administered_on"`
+	DueOn          *Date     `db:"due_on" json:"due_on"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MedicalRecordRequest is the body of the medical record writes. Dates are
+// formatted as DateLayout.
+type MedicalRecordRequest struct {
+	Kind           string  `json:"kind" binding:"required,oneof=diagnosis treatment vaccination"`
+	Title          string  `json:"title" binding:"required,max=200"`
+	Notes          string  `json:"notes" binding:"max=5000"`
+	AdministeredOn string  `json:"administered_on" binding:"required,datetime=2006-01-02"`
+	DueOn          *string `json:"due_on" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// MedicalRecordListOptions describes which page of the records of an animal a
+// list call should return. Records are ordered by AdministeredOn, then id.
+type MedicalRecordListOptions struct {
+	Limit int
+	// After is the position of the last record of the previous page.
+	After *MedicalRecordCursor
+	Kind  *string
+}
+
+// DueVaccinationOptions describes which page of due vaccinations a list call
+// should return. Vaccinations are ordered by DueOn, then id.
+type DueVaccinationOptions struct {
+	Limit int
+	// Before excludes vaccinations due on that day or later.
+	Before Date
+	After  *MedicalRecordCursor
+}
+
+// MedicalRecordCursor is the keyset position of a record in a page ordered by a date.
+type MedicalRecordCursor struct {
+	Date Date
+	ID   int64
+}
+
+// MedicalRecordPage is a single page of medical records.
+type MedicalRecordPage struct {
+	Records []MedicalRecord
+	HasMore bool
+}
+
+// MedicalRecordListResponse is the envelope returned by GET /animals/:id/medical-records
+// and GET /vaccinations/due.
+type MedicalRecordListResponse struct {
+	Data       []MedicalRecord `json:"data"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// Owner is a person who adopted, or may adopt, animals.
+type Owner struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Email     *string   `db:"email" json:"email"`
+	Phone     *string   `db:"phone" json:"phone"`
+	Address   string    `db:"address" json:"address"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type OwnerRequest struct {
+	Name    string  `json:"name" binding:"required,max=200"`
+	Email   *string `json:"email" binding:"omitempty,email,max=254"`
+	Phone   *string `json:"phone" binding:"omitempty,max=50"`
+	Address string  `json:"address" binding:"max=500"`
+}
+
+// OwnerListOptions describes which page of owners a list call should return.
+type OwnerListOptions struct {
+	Limit   int
+	AfterID int64
+	// Email matches regardless of case.
+	Email *string
+}
+
+// OwnerPage is a single page of owners ordered by id.
+type OwnerPage struct {
+	Owners  []Owner
+	HasMore bool
+}
+
+// OwnerListResponse is the envelope returned by GET /owners.
+type OwnerListResponse struct {
+	Data       []Owner `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Adoption records that an owner took an animal home on AdoptedOn. ReturnedOn is
+// the day the animal came back to the shelter, if it did.
+type Adoption struct {
+	ID         int64     `db:"id" json:"id"`
+	AnimalID   int64     `db:"animal_id" json:"animal_id"`
+	OwnerID    int64     `db:"owner_id" json:"owner_id"`
+	AdoptedOn  Date      `db:"adopted_on" json:"adopted_on"`
+	ReturnedOn *Date     `db:"returned_on" json:"returned_on"`
+	FeeCents   int64     `db:"fee_cents" json:"fee_cents"`
+	Notes      string    `db:"notes" json:"notes"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AdoptionRequest is the body of the adoption writes. Dates are formatted as
+// DateLayout, and the fee is in cents.
+type AdoptionRequest struct {
+	OwnerID    int64   `json:"owner_id" binding:"required,min=1"`
+	AdoptedOn  string  `json:"adopted_on" binding:"required,datetime=2006-01-02"`
+	ReturnedOn *string `json:"returned_on" binding:"omitempty,datetime=2006-01-02"`
+	FeeCents   int64   `json:"fee_cents" binding:"min=0"`
+	Notes      string  `json:"notes" binding:"max=5000"`
+}
+
+// StatusTransitionRequest is the body of POST /animals/:id/transitions.
+type StatusTransitionRequest struct {
+	To     string `json:"to" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// StatusChange is an entry of the status history of an animal.
+type StatusChange struct {
+	ID        int64     `db:"id" json:"id"`
+	AnimalID  int64     `db:"animal_id" json:"animal_id"`
+	From      string    `db:"from_status" json:"from"`
+	To        string    `db:"to_status" json:"to"`
+	Reason    string    `db:"reason" json:"reason"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// Enclosure is a place where animals are housed. It holds at most Capacity
+// animals. When AllowedSpecies is not empty, it only takes animals of those
+// taxa or of taxa below them.
+type Enclosure struct {
+	ID             int64         `db:"id" json:"id"`
+	Name           string        `db:"name" json:"name"`
+	Capacity       int           `db:"capacity" json:"capacity"`
+	AllowedSpecies pq.Int64Array `db:"allowed_species" json:"allowed_species"`
+	CreatedAt      time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+type EnclosureRequest struct {
+	Name           string  `json:"name" binding:"required,max=200"`
+	Capacity       int     `json:"capacity" binding:"required,min=1,max=10000"`
+	AllowedSpecies []int64 `json:"allowed_species" binding:"max=50,dive,min=1"`
+}
+
+// EnclosureListOptions describes which page of enclosures a list call should return.
+type EnclosureListOptions struct {
+	Limit   int
+	AfterID int64
+}
+
+// EnclosurePage is a single page of enclosures ordered by id.
+type EnclosurePage struct {
+	Enclosures []Enclosure
+	HasMore    bool
+}
+
+// EnclosureListResponse is the envelope returned by GET /enclosures.
+type EnclosureListResponse struct {
+	Data       []Enclosure `json:"data"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// MoveRequest is the body of POST /animals/:id/move. A nil EnclosureID takes
+// the animal out of its enclosure.
+type MoveRequest struct {
+	EnclosureID *int64 `json:"enclosure_id" binding:"omitempty,min=1"`
+}
+
+// Occupancy is the number of animals an enclosure houses, against its capacity.
+type Occupancy struct {
+	EnclosureID int64  `db:"enclosure_id" json:"enclosure_id"`
+	Name        string `db:"name" json:"name"`
+	Capacity    int    `db:"capacity" json:"capacity"`
+	Occupants   int    `db:"occupants" json:"occupants"`
+	Free        int    `db:"free" json:"free"`
+}
+
+// OccupancyReport is the occupancy of every enclosure, ordered by id, with the
+// totals across them. Unhoused counts the animals in the shelter that are not
+// in any enclosure.
+type OccupancyReport struct {
+	Data   []Occupancy `json:"data"`
+	Totals struct {
+		Capacity  int `json:"capacity"`
+		Occupants int `json:"occupants"`
+		Free      int `json:"free"`
+		Unhoused  int `json:"unhoused"`
+	} `json:"totals"`
 }
@@ -3,6 +3,8 @@ package animal
 import (
 	"log/slog"
 
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/diegotremper/go-animals/internal/idempotency"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 )
@@ -13,4 +15,10 @@ type Module interface {
 	NewTransactionLogger(ctx *gin.Context) *slog.Logger
 	Db() *sqlx.DB
 	RouterGroup() *gin.RouterGroup
+	// AdminRouterGroup is mounted under /admin and only lets administrators through.
+	AdminRouterGroup() *gin.RouterGroup
+	// IdempotencyStore keeps the responses of requests sent with an Idempotency-Key.
+	IdempotencyStore() idempotency.Store
+	// BlobStore keeps the content of attachments.
+	BlobStore() blob.Store
 }
@@ -0,0 +1,235 @@
+package animal
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// format is a representation that animals can be read and written in.
+type format struct {
+	// mediaTypes name the format; the first one is sent as Content-Type
+	mediaTypes  []string
+	contentType string
+	encode      func(w io.Writer, doc document, tree any) error
+	// decode reads a request body into a tree; list tells whether an array is expected
+	decode func(body []byte, list bool) (any, error)
+	// untyped formats carry every value as a string
+	untyped bool
+}
+
+var formatJSON = &format{mediaTypes: []string{gin.MIMEJSON}}
+
+// formats are the representations offered, in order of preference.
+var formats = []*format{
+	formatJSON,
+	{
+		mediaTypes:  []string{"text/csv"},
+		contentType: "text/csv; charset=utf-8",
+		encode:      encodeCSV,
+		decode:      decodeCSV,
+		untyped:     true,
+	},
+	{
+		mediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		contentType: "application/yaml; charset=utf-8",
+		encode:      encodeYAML,
+		decode:      decodeYAML,
+	},
+	{
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		contentType: "application/xml; charset=utf-8",
+		encode:      encodeXML,
+		decode:      decodeXML,
+		untyped:     true,
+	},
+	{
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		contentType: "application/msgpack",
+		encode:      encodeMsgpack,
+		decode:      decodeMsgpack,
+	},
+}
+
+// formatFor returns the format named by a media type, or nil.
+func formatFor(mediaType string) *format {
+	for _, f := range formats {
+		if slices.Contains(f.mediaTypes, strings.ToLower(mediaType)) {
+			return f
+		}
+	}
+	return nil
+}
+
+// offeredMediaTypes lists the primary media type of every format.
+func offeredMediaTypes() string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.mediaTypes[0]
+	}
+	return strings.Join(names, ", ")
+}
+
+// negotiateFormat picks the format of a response from an Accept header. Media
+// ranges are tried by descending q-value, and a range with q=0 rules a format
+// out. Without the header the response is JSON.
+func negotiateFormat(accept string) (*format, error) {
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, nil
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var (
+		ranges   []mediaRange
+		excluded []*format
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			if f := formatFor(mediaType); f != nil {
+				excluded = append(excluded, f)
+			}
+			continue
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int { return cmp.Compare(b.q, a.q) })
+
+	for _, r := range ranges {
+		for _, f := range formats {
+			if slices.Contains(excluded, f) {
+				continue
+			}
+			if r.mediaType == "*/*" || slices.ContainsFunc(f.mediaTypes, func(t string) bool {
+				return t == r.mediaType || strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(t, strings.TrimSuffix(r.mediaType, "*"))
+			}) {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: animals can be sent as %s", ErrNotAcceptable, offeredMediaTypes())
+}
+
+// requireAcceptable answers 406 Not Acceptable before the handler runs when
+// none of the formats of render is acceptable, so that no write is made whose
+// result cannot be sent.
+func requireAcceptable(module Module) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, err := negotiateFormat(ctx.GetHeader("Accept")); err != nil {
+			writeProblem(ctx, module, err)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// document describes the shape of what render sends, for the formats that need
+// more than the value itself: the root element of XML, and the columns of CSV
+// and the member holding its records, if the records are not the document itself.
+type document struct {
+	root    string
+	records string
+	columns []string
+}
+
+// animalDocument is the shape of a single animal read with p.
+func animalDocument(p Projection) document {
+	return document{root: "animal", columns: p.members()}
+}
+
+// animalListDocument is the shape of a list of animals read with p.
+func animalListDocument(p Projection) document {
+	return document{root: "animals", records: "data", columns: p.members()}
+}
+
+// render sends v in the format the Accept header asks for.
+func render(ctx *gin.Context, module Module, status int, doc document, v any) {
+	ctx.Header("Vary", "Accept")
+	f, err := negotiateFormat(ctx.GetHeader("Accept"))
+	if err != nil {
+		writeProblem(ctx, module, err)
+		return
+	}
+	if f == formatJSON {
+		ctx.JSON(status, v)
+		return
+	}
+
+	var buf bytes.Buffer
+	tree, err := toTree(v)
+	if err == nil {
+		err = f.encode(&buf, doc, tree)
+	}
+	if err != nil {
+		writeProblem(ctx, module, fmt.Errorf("error rendering %s: %w", f.mediaTypes[0], err))
+		return
+	}
+	ctx.Data(status, f.contentType, buf.Bytes())
+}
+
+// bindBody decodes a request body in any of the formats into obj and validates
+// it like ShouldBindJSON. A body without Content-Type is taken to be JSON.
+func bindBody(ctx *gin.Context, obj any) error {
+	body, err := requestJSON(ctx, reflect.TypeOf(obj).Elem())
+	if err != nil {
+		return err
+	}
+	if err := binding.JSON.BindBody(body, obj); err != nil {
+		return bindingError(err)
+	}
+	return nil
+}
+
+// requestJSON reads the request body and translates it to JSON. t is the type
+// the body is decoded into; untyped formats convert their values to its fields.
+func requestJSON(ctx *gin.Context, t reflect.Type) ([]byte, error) {
+	f := formatJSON
+	if contentType := ctx.ContentType(); contentType != "" {
+		if f = formatFor(contentType); f == nil {
+			return nil, fmt.Errorf("%w: %q is not supported, send one of %s", ErrUnsupportedMediaType, contentType, offeredMediaTypes())
+		}
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	if f == formatJSON {
+		return body, nil
+	}
+
+	tree, err := f.decode(body, t.Kind() == reflect.Slice)
+	if err != nil {
+		return nil, badRequest("malformed %s body: %v", f.mediaTypes[0], err)
+	}
+	if f.untyped {
+		tree = coerceTree(tree, t)
+	}
+	body, err = json.Marshal(tree)
+	if err != nil {
+		return nil, badRequest("malformed %s body: %v", f.mediaTypes[0], err)
+	}
+	return body, nil
+}
@@ -0,0 +1,110 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ownerColumns is the select list that matches the Owner struct.
+const ownerColumns = `id, name, email, phone, address, created_at, updated_at`
+
+type OwnerRepository interface {
+	CreateOwner(r OwnerRequest) (Owner, error)
+	UpdateOwner(id int64, r OwnerRequest) (Owner, error)
+	ListOwners(opts OwnerListOptions) (OwnerPage, error)
+	GetOwner(id int64) (Owner, error)
+	// DeleteOwner fails with ErrOwnerHasAdoptions while adoptions reference the
+	// owner, since they are the shelter's record of where its animals went.
+	DeleteOwner(id int64) error
+}
+
+type PostgresOwnerRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresOwnerRepository(db *sqlx.DB) *PostgresOwnerRepository {
+	return &PostgresOwnerRepository{db: db}
+}
+
+func (r *PostgresOwnerRepository) CreateOwner(req OwnerRequest) (Owner, error) {
+	var owner Owner
+	err := r.db.QueryRowx(`INSERT INTO owners (name, email, phone, address) VALUES ($1, $2, $3, $4) RETURNING `+ownerColumns,
+		req.Name, req.Email, req.Phone, req.Address).StructScan(&owner)
+	if err != nil {
+		return Owner{}, fmt.Errorf("failed to insert owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (r *PostgresOwnerRepository) UpdateOwner(id int64, req OwnerRequest) (Owner, error) {
+	var owner Owner
+	err := r.db.QueryRowx(`UPDATE owners SET name = $1, email = $2, phone = $3, address = $4, updated_at = now()
+		WHERE id = $5 RETURNING `+ownerColumns,
+		req.Name, req.Email, req.Phone, req.Address, id).StructScan(&owner)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Owner{}, fmt.Errorf("%w: id=%d", ErrOwnerNotFound, id)
+		}
+		return Owner{}, fmt.Errorf("failed to update owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (r *PostgresOwnerRepository) ListOwners(opts OwnerListOptions) (OwnerPage, error) {
+	var (
+		owners = []Owner{}
+		where  = []string{"id > $1"}
+		args   = []any{opts.AfterID}
+	)
+	if opts.Email != nil {
+		args = append(args, *opts.Email)
+		where = append(where, fmt.Sprintf("lower(email) = lower($%d)", len(args)))
+	}
+	args = append(args, opts.Limit+1)
+
+	err := r.db.Select(&owners, `SELECT `+ownerColumns+` FROM owners WHERE `+strings.Join(where, " AND ")+
+		fmt.Sprintf(` ORDER BY id LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return OwnerPage{}, fmt.Errorf("ListOwners query error: %w", err)
+	}
+
+	page := OwnerPage{Owners: owners}
+	if len(owners) > opts.Limit {
+		page.Owners, page.HasMore = owners[:opts.Limit], true
+	}
+	return page, nil
+}
+
+func (r *PostgresOwnerRepository) GetOwner(id int64) (Owner, error) {
+	var owner Owner
+	err := r.db.Get(&owner, `SELECT `+ownerColumns+` FROM owners WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Owner{}, fmt.Errorf("%w: id=%d", ErrOwnerNotFound, id)
+		}
+		return Owner{}, fmt.Errorf("error getting owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (r *PostgresOwnerRepository) DeleteOwner(id int64) error {
+	res, err := r.db.Exec(`DELETE FROM owners WHERE id = $1`, id)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			return fmt.Errorf("%w: id=%d", ErrOwnerHasAdoptions, id)
+		}
+		return fmt.Errorf("failed to delete owner: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrOwnerNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownerListParams are the query parameters accepted by GET /owners.
+var ownerListParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+	"email":  true,
+}
+
+type OwnerHandler struct {
+	module  Module
+	repo    OwnerRepository
+	animals AnimalRepository
+}
+
+func NewOwnerHandler(module Module, repo OwnerRepository, animals AnimalRepository) *OwnerHandler {
+	return &OwnerHandler{module: module, repo: repo, animals: animals}
+}
+
+func (h *OwnerHandler) CreateOwnerHandler(ctx *gin.Context) {
+	var req OwnerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	owner, err := h.repo.CreateOwner(req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/owners/%d", owner.ID))
+	ctx.JSON(http.StatusCreated, owner)
+}
+
+func (h *OwnerHandler) UpdateOwnerHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req OwnerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	owner, err := h.repo.UpdateOwner(id, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, owner)
+}
+
+func (h *OwnerHandler) ListOwnersHandler(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	for param := range query {
+		if !ownerListParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parsePageParams(ctx, nil)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts := OwnerListOptions{Limit: limit}
+	if after != nil {
+		opts.AfterID = after.ID
+	}
+	if query.Has("email") {
+		email := query.Get("email")
+		opts.Email = &email
+	}
+
+	page, err := h.repo.ListOwners(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if page.HasMore {
+		token := EncodeCursor(Cursor{ID: page.Owners[len(page.Owners)-1].ID})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, OwnerListResponse{Data: page.Owners, NextCursor: nextCursor})
+}
+
+func (h *OwnerHandler) GetOwnerHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	owner, err := h.repo.GetOwner(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, owner)
+}
+
+func (h *OwnerHandler) DeleteOwnerHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteOwner(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Owner deleted successfully"})
+}
+
+// ListOwnerAnimalsHandler lists the animals an owner adopted and has not
+// returned. It accepts the query parameters of GET /animals.
+func (h *OwnerHandler) ListOwnerAnimalsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetOwner(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx, false)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts.Owner = &id
+
+	page, err := h.animals.ListAnimals(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	writeAnimalPage(ctx, h.module, h.animals, opts, page)
+}
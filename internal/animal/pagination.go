@@ -0,0 +1,155 @@
+package animal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrBadRequest)
+
+// Cursor is the keyset position a page starts after. It is handed to clients
+// as an opaque base64url token, so its fields may change without notice.
+// Values holds the sort key values of the last row, in the order of Sort.
+type Cursor struct {
+	ID     int64  `json:"id"`
+	Sort   string `json:"s,omitempty"`
+	Values []any  `json:"v,omitempty"`
+}
+
+// cursorAfter returns the cursor positioned after animal a for the given sort order.
+func cursorAfter(a Animal, sort []SortField) Cursor {
+	c := Cursor{ID: a.ID, Sort: sortKey(sort)}
+	for _, f := range sort {
+		c.Values = append(c.Values, sortValue(a, f.Field))
+	}
+	return c
+}
+
+func sortValue(a Animal, field string) any {
+	switch field {
+	case "name":
+		return a.Name
+	case "age":
+		return a.BirthDate
+	case "created_at":
+		return a.CreatedAt
+	case "updated_at":
+		return a.UpdatedAt
+	default:
+		return a.ID
+	}
+}
+
+// checkSortValue makes sure a cursor value, as decoded from JSON, has the type
+// of its sort field, so that a tampered cursor fails here and not in the query.
+func checkSortValue(field string, v any) error {
+	s, isString := v.(string)
+	var kind string
+	switch field {
+	case "id":
+		n, ok := v.(float64)
+		if ok && n == math.Trunc(n) {
+			return nil
+		}
+		kind = "an integer"
+	case "name":
+		if isString {
+			return nil
+		}
+		kind = "a string"
+	case "created_at", "updated_at":
+		if _, err := time.Parse(time.RFC3339Nano, s); isString && err == nil {
+			return nil
+		}
+		kind = "a timestamp"
+	case "age":
+		// a sort by age is positioned by birth date
+		if isString && validDate(s) {
+			return nil
+		}
+		kind = "a birth date"
+	default:
+		if isString && validDate(s) {
+			return nil
+		}
+		kind = "a date"
+	}
+	return fmt.Errorf("%w: cursor does not hold %s to sort by %s", ErrInvalidCursor, kind, field)
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// parsePageParams reads the limit and cursor query parameters.
+// The cursor must have been issued for the same sort order.
+func parsePageParams(ctx *gin.Context, sort []SortField) (int, *Cursor, error) {
+	limit := DefaultPageLimit
+	if v := ctx.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPageLimit {
+			return 0, nil, badRequest("limit must be an integer between 1 and %d", MaxPageLimit)
+		}
+		limit = n
+	}
+
+	var after *Cursor
+	if v := ctx.Query("cursor"); v != "" {
+		c, err := DecodeCursor(v)
+		if err != nil {
+			return 0, nil, err
+		}
+		if c.Sort != sortKey(sort) || len(c.Values) != len(sort) {
+			return 0, nil, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+		}
+		for i, f := range sort {
+			if err := checkSortValue(f.Field, c.Values[i]); err != nil {
+				return 0, nil, err
+			}
+		}
+		after = &c
+	}
+
+	return limit, after, nil
+}
+
+// pageLinks builds the RFC 8288 Link header value for a list response,
+// keeping every query parameter of the current request except the cursor.
+func pageLinks(ctx *gin.Context, limit int, nextCursor *string) string {
+	query := ctx.Request.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Del("cursor")
+
+	links := []string{fmt.Sprintf(`<%s?%s>; rel="first"`, ctx.Request.URL.Path, query.Encode())}
+	if nextCursor != nil {
+		query.Set("cursor", *nextCursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="next"`, ctx.Request.URL.Path, query.Encode()))
+	}
+
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,234 @@
+package animal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	ErrMalformedPatch     = fmt.Errorf("%w: malformed patch document", ErrBadRequest)
+	ErrUnprocessablePatch = fmt.Errorf("%w: patch cannot be applied", ErrValidation)
+	ErrPatchTestFailed    = fmt.Errorf("%w: patch test operation failed", ErrConflict)
+)
+
+// decodeMergePatch reads an RFC 7396 JSON Merge Patch document. Members that are
+// present replace the stored value; null clears it back to its default. Since
+// both set the birth date, age and birth_date cannot be given together.
+func decodeMergePatch(body []byte) (AnimalPatch, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return AnimalPatch{}, fmt.Errorf("%w: %v", ErrMalformedPatch, err)
+	}
+	if doc == nil {
+		return AnimalPatch{}, fmt.Errorf("%w: document must be a JSON object", ErrMalformedPatch)
+	}
+
+	_, age := doc["age"]
+	if _, birthDate := doc["birth_date"]; age && birthDate {
+		return AnimalPatch{}, &ValidationError{Fields: []problem.FieldError{{Field: "age", Code: "invalid", Message: "must not be given together with birth_date"}}}
+	}
+
+	var patch AnimalPatch
+	for _, member := range slices.Sorted(maps.Keys(doc)) {
+		raw := doc[member]
+		if isJSONNull(raw) {
+			if err := clearField(&patch.Set, member); err != nil {
+				return AnimalPatch{}, err
+			}
+			continue
+		}
+		if err := setField(&patch.Set, member, raw); err != nil {
+			return AnimalPatch{}, err
+		}
+	}
+	return patch, nil
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodeJSONPatch reads an RFC 6902 JSON Patch document. Operations are applied
+// in order to the top-level members of an animal: add and replace write a value,
+// remove clears it and test becomes a precondition of the update. Since the
+// stored document is not loaded, move and copy are not supported.
+func decodeJSONPatch(body []byte) (AnimalPatch, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return AnimalPatch{}, fmt.Errorf("%w: %v", ErrMalformedPatch, err)
+	}
+
+	var patch AnimalPatch
+	for i, op := range ops {
+		member, err := patchPathMember(op.Path)
+		if err != nil {
+			return AnimalPatch{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return AnimalPatch{}, fmt.Errorf("%w: operation %d: %q requires a value", ErrMalformedPatch, i, op.Op)
+			}
+			err = setField(&patch.Set, member, op.Value)
+		case "remove":
+			err = clearField(&patch.Set, member)
+		case "test":
+			if op.Value == nil {
+				return AnimalPatch{}, fmt.Errorf("%w: operation %d: \"test\" requires a value", ErrMalformedPatch, i)
+			}
+			err = addTest(&patch, member, op.Value)
+		case "move", "copy":
+			err = fmt.Errorf("%w: operation %q is not supported", ErrUnprocessablePatch, op.Op)
+		default:
+			err = fmt.Errorf("%w: unknown operation %q", ErrMalformedPatch, op.Op)
+		}
+		if err != nil {
+			return AnimalPatch{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return patch, nil
+}
+
+// addTest records a test operation. A member that an earlier operation already
+// wrote is compared against that value right away.
+func addTest(patch *AnimalPatch, member string, raw json.RawMessage) error {
+	var want AnimalFields
+	if err := setField(&want, member, raw); err != nil {
+		return err
+	}
+
+	if current, ok := fieldValue(patch.Set, member); ok {
+		if tested, _ := fieldValue(want, member); current != tested {
+			return fmt.Errorf("%w: %s", ErrPatchTestFailed, member)
+		}
+		return nil
+	}
+	if previous, ok := fieldValue(patch.Test, member); ok {
+		if tested, _ := fieldValue(want, member); previous != tested {
+			return fmt.Errorf("%w: %s", ErrPatchTestFailed, member)
+		}
+		return nil
+	}
+	return setField(&patch.Test, member, raw)
+}
+
+func patchPathMember(path string) (string, error) {
+	member, ok := strings.CutPrefix(path, "/")
+	if !ok || strings.Contains(member, "/") {
+		return "", fmt.Errorf("%w: unsupported path %q", ErrUnprocessablePatch, path)
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(member), nil
+}
+
+func setField(fields *AnimalFields, member string, raw json.RawMessage) error {
+	var err error
+	switch member {
+	case "name":
+		fields.Name = new(string)
+		err = json.Unmarshal(raw, fields.Name)
+	case "age":
+		fields.Age = new(int)
+		err = json.Unmarshal(raw, fields.Age)
+	case "birth_date":
+		fields.BirthDate = new(string)
+		err = json.Unmarshal(raw, fields.BirthDate)
+	case "birth_date_estimated":
+		fields.BirthDateEstimated = new(bool)
+		err = json.Unmarshal(raw, fields.BirthDateEstimated)
+	case "description":
+		fields.Description = new(string)
+		err = json.Unmarshal(raw, fields.Description)
+	case "species_id":
+		fields.SpeciesID = new(int64)
+		// null unsets the species, which the patch stores as 0
+		if !isJSONNull(raw) {
+			err = json.Unmarshal(raw, fields.SpeciesID)
+		}
+	case "sire_id":
+		fields.SireID = new(int64)
+		if !isJSONNull(raw) {
+			err = json.Unmarshal(raw, fields.SireID)
+		}
+	case "dam_id":
+		fields.DamID = new(int64)
+		if !isJSONNull(raw) {
+			err = json.Unmarshal(raw, fields.DamID)
+		}
+	case "id":
+		return fmt.Errorf("%w: id cannot be changed", ErrUnprocessablePatch)
+	case "status":
+		return fmt.Errorf("%w: status can only be changed with POST /animals/:id/transitions", ErrUnprocessablePatch)
+	case "enclosure_id":
+		return fmt.Errorf("%w: enclosure_id can only be changed with POST /animals/:id/move", ErrUnprocessablePatch)
+	default:
+		return fmt.Errorf("%w: unknown member %q", ErrUnprocessablePatch, member)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: invalid value for %s: %v", ErrUnprocessablePatch, member, err)
+	}
+	return nil
+}
+
+// clearField resets a member to the value a new animal gets when it is omitted.
+func clearField(fields *AnimalFields, member string) error {
+	switch member {
+	case "age", "birth_date":
+		// a new animal without either is taken to be born today
+		fields.Age = new(int)
+	case "birth_date_estimated":
+		fields.BirthDateEstimated = new(bool)
+	case "description":
+		fields.Description = new(string)
+	case "species_id":
+		fields.SpeciesID = new(int64)
+	case "sire_id":
+		fields.SireID = new(int64)
+	case "dam_id":
+		fields.DamID = new(int64)
+	case "name":
+		return fmt.Errorf("%w: name cannot be removed", ErrUnprocessablePatch)
+	default:
+		return setField(fields, member, nil)
+	}
+	return nil
+}
+
+func fieldValue(fields AnimalFields, member string) (any, bool) {
+	switch {
+	case member == "name" && fields.Name != nil:
+		return *fields.Name, true
+	case member == "age" && fields.Age != nil:
+		return *fields.Age, true
+	case member == "birth_date" && fields.BirthDate != nil:
+		return *fields.BirthDate, true
+	case member == "birth_date_estimated" && fields.BirthDateEstimated != nil:
+		return *fields.BirthDateEstimated, true
+	case member == "description" && fields.Description != nil:
+		return *fields.Description, true
+	case member == "species_id" && fields.SpeciesID != nil:
+		return *fields.SpeciesID, true
+	case member == "sire_id" && fields.SireID != nil:
+		return *fields.SireID, true
+	case member == "dam_id" && fields.DamID != nil:
+		return *fields.DamID, true
+	}
+	return nil, false
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
@@ -0,0 +1,184 @@
+package animal
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPedigreeDepth = 3
+	MaxPedigreeDepth     = 10
+	// inbreedingDepth is how many generations of ancestors the inbreeding
+	// coefficient takes into account.
+	inbreedingDepth = 20
+)
+
+// pedigreeParams are the query parameters accepted by the pedigree graphs.
+var pedigreeParams = map[string]bool{
+	"depth": true,
+}
+
+func (h *AnimalHandler) AncestorsHandler(ctx *gin.Context) {
+	h.pedigreeGraph(ctx, h.repo.Ancestors)
+}
+
+func (h *AnimalHandler) DescendantsHandler(ctx *gin.Context) {
+	h.pedigreeGraph(ctx, h.repo.Descendants)
+}
+
+func (h *AnimalHandler) pedigreeGraph(ctx *gin.Context, load func(id int64, depth int) (PedigreeGraph, error)) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	depth, err := parseDepth(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	graph, err := load(id, depth)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, graph)
+}
+
+// parseDepth reads the number of generations a pedigree graph spans.
+func parseDepth(ctx *gin.Context) (int, error) {
+	for param := range ctx.Request.URL.Query() {
+		if !pedigreeParams[param] {
+			return 0, badRequest("unknown query parameter %q", param)
+		}
+	}
+
+	v, ok := ctx.GetQuery("depth")
+	if !ok {
+		return DefaultPedigreeDepth, nil
+	}
+	depth, err := strconv.Atoi(v)
+	if err != nil || depth < 1 || depth > MaxPedigreeDepth {
+		return 0, badRequest("depth must be an integer between 1 and %d", MaxPedigreeDepth)
+	}
+	return depth, nil
+}
+
+// InbreedingHandler reports Wright's coefficient of inbreeding of an animal: the
+// probability that both copies of a gene are inherited from the same ancestor.
+// Ancestors without recorded parents count as unrelated founders.
+func (h *AnimalHandler) InbreedingHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	graph, err := h.repo.Ancestors(id, inbreedingDepth)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Inbreeding{
+		AnimalID:    id,
+		Coefficient: newKinship(graph.Edges).inbreeding(id),
+		Generations: maxDepth(graph.Nodes),
+	})
+}
+
+func maxDepth(nodes []PedigreeNode) int {
+	depth := 0
+	for _, n := range nodes {
+		depth = max(depth, n.Depth)
+	}
+	return depth
+}
+
+// kinship computes coefficients of kinship over a pedigree with the recursive
+// method: the kinship of two animals is the mean kinship of the younger one's
+// parents with the other, and that of an animal with itself is (1 + F) / 2.
+// Expanding the younger animal makes sure it is never an ancestor of the other.
+type kinship struct {
+	parents     map[int64][2]int64
+	generations map[int64]int
+	memo        map[[2]int64]float64
+}
+
+func newKinship(edges []PedigreeEdge) *kinship {
+	k := &kinship{
+		parents:     map[int64][2]int64{},
+		generations: map[int64]int{},
+		memo:        map[[2]int64]float64{},
+	}
+	for _, e := range edges {
+		parents := k.parents[e.ChildID]
+		if e.Relation == RelationSire {
+			parents[0] = e.ParentID
+		} else {
+			parents[1] = e.ParentID
+		}
+		k.parents[e.ChildID] = parents
+	}
+	return k
+}
+
+// inbreeding is the coefficient of inbreeding of id, which is the kinship of its parents.
+func (k *kinship) inbreeding(id int64) float64 {
+	parents := k.parents[id]
+	return k.kinship(parents[0], parents[1])
+}
+
+// kinship returns the coefficient of kinship of a and b. An id of 0 is an
+// unknown animal, related to nobody.
+func (k *kinship) kinship(a, b int64) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a == b {
+		return (1 + k.inbreeding(a)) / 2
+	}
+
+	key := [2]int64{min(a, b), max(a, b)}
+	if v, ok := k.memo[key]; ok {
+		return v
+	}
+	if k.generation(a) < k.generation(b) {
+		a, b = b, a
+	}
+	parents := k.parents[a]
+	v := (k.kinship(parents[0], b) + k.kinship(parents[1], b)) / 2
+	k.memo[key] = v
+	return v
+}
+
+// generation is 0 for founders and otherwise one more than the highest generation
+// of its parents, so an animal always has a higher generation than its ancestors.
+func (k *kinship) generation(id int64) int {
+	if g, ok := k.generations[id]; ok {
+		return g
+	}
+	g := 0
+	for _, parent := range k.parents[id] {
+		if parent != 0 {
+			g = max(g, k.generation(parent)+1)
+		}
+	}
+	k.generations[id] = g
+	return g
+}
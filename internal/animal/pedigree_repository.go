@@ -0,0 +1,142 @@
+package animal
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// pedigreeLock is the key of the advisory lock that serializes changes to the
+// parents of animals, see checkPedigree.
+const pedigreeLock = 0x7065646967726565
+
+// ancestorEdges selects the parent links above animal $1, up to $2 generations.
+// An edge reached on several paths is listed once, at its smallest depth.
+const ancestorEdges = `WITH RECURSIVE edges (parent_id, child_id, relation, depth) AS (
+		SELECT p.parent_id, a.id, p.relation, 1
+		FROM animals a CROSS JOIN LATERAL (VALUES (a.sire_id, 'sire'::text), (a.dam_id, 'dam'::text)) p (parent_id, relation)
+		WHERE a.id = $1 AND p.parent_id IS NOT NULL
+		UNION
+		SELECT p.parent_id, a.id, p.relation, e.depth + 1
+		FROM edges e JOIN animals a ON a.id = e.parent_id
+		CROSS JOIN LATERAL (VALUES (a.sire_id, 'sire'::text), (a.dam_id, 'dam'::text)) p (parent_id, relation)
+		WHERE p.parent_id IS NOT NULL AND e.depth < $2
+	) SELECT parent_id, child_id, relation, min(depth) AS depth FROM edges
+	GROUP BY parent_id, child_id, relation ORDER BY depth, child_id, relation DESC`
+
+// descendantEdges selects the parent links below animal $1, up to $2 generations.
+const descendantEdges = `WITH RECURSIVE edges (parent_id, child_id, relation, depth) AS (
+		SELECT $1::bigint, c.id, CASE WHEN c.sire_id = $1 THEN 'sire' ELSE 'dam' END, 1
+		FROM animals c WHERE c.sire_id = $1 OR c.dam_id = $1
+		UNION
+		SELECT e.child_id, c.id, CASE WHEN c.sire_id = e.child_id THEN 'sire' ELSE 'dam' END, e.depth + 1
+		FROM edges e JOIN animals c ON c.sire_id = e.child_id OR c.dam_id = e.child_id
+		WHERE e.depth < $2
+	) SELECT parent_id, child_id, relation, min(depth) AS depth FROM edges
+	GROUP BY parent_id, child_id, relation ORDER BY depth, parent_id, child_id`
+
+func (r *PostgresAnimalRepository) Ancestors(id int64, depth int) (PedigreeGraph, error) {
+	return r.pedigree(id, depth, ancestorEdges, func(e PedigreeEdge) int64 { return e.ParentID })
+}
+
+func (r *PostgresAnimalRepository) Descendants(id int64, depth int) (PedigreeGraph, error) {
+	return r.pedigree(id, depth, descendantEdges, func(e PedigreeEdge) int64 { return e.ChildID })
+}
+
+// pedigree loads the edges selected by query and the animals they connect. far
+// picks the end of an edge that lies away from the root.
+func (r *PostgresAnimalRepository) pedigree(id int64, depth int, query string, far func(PedigreeEdge) int64) (PedigreeGraph, error) {
+	edges := []PedigreeEdge{}
+	if err := r.db.Select(&edges, query, id, depth); err != nil {
+		return PedigreeGraph{}, fmt.Errorf("pedigree query error: %w", err)
+	}
+
+	depths := map[int64]int{id: 0}
+	for _, e := range edges {
+		if _, seen := depths[far(e)]; !seen {
+			// edges come ordered by depth, so the first one is the closest
+			depths[far(e)] = e.Depth
+		}
+	}
+	ids := make([]int64, 0, len(depths))
+	for nodeID := range depths {
+		ids = append(ids, nodeID)
+	}
+
+	var animals []Animal
+	if err := r.db.Select(&animals, `SELECT `+animalColumns+` FROM `+liveAnimals+` WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return PedigreeGraph{}, fmt.Errorf("pedigree animals query error: %w", err)
+	}
+	if len(animals) == 0 {
+		return PedigreeGraph{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+	}
+
+	graph := PedigreeGraph{RootID: id, Depth: depth, Edges: edges, Nodes: make([]PedigreeNode, len(animals))}
+	for i, a := range animals {
+		if a.DeletedAt != nil {
+			// soft deleted animals are hidden like everywhere else, but keep their place
+			graph.Nodes[i] = PedigreeNode{Animal: Animal{ID: a.ID}, Depth: depths[a.ID], Deleted: true}
+			continue
+		}
+		graph.Nodes[i] = PedigreeNode{Animal: a, Depth: depths[a.ID]}
+	}
+	slices.SortFunc(graph.Nodes, func(a, b PedigreeNode) int {
+		return cmp.Or(cmp.Compare(a.Depth, b.Depth), cmp.Compare(a.ID, b.ID))
+	})
+	return graph, nil
+}
+
+// checkPedigree makes sure the new parents of animal id are neither the animal
+// itself nor one of its descendants, either of which would close a cycle. The
+// check holds a lock until tx ends, so that two concurrent writes cannot close a
+// cycle between them either.
+func checkPedigree(tx *sqlx.Tx, id int64, sire, dam *int64) error {
+	parents := map[string]*int64{"sire_id": sire, "dam_id": dam}
+	var ids []int64
+	for _, field := range []string{"sire_id", "dam_id"} {
+		parent := parents[field]
+		if parent == nil {
+			continue
+		}
+		if *parent == id {
+			return errSelfParent(field)
+		}
+		ids = append(ids, *parent)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, pedigreeLock); err != nil {
+		return fmt.Errorf("failed to lock pedigree: %w", err)
+	}
+
+	var descendants []int64
+	err := tx.Select(&descendants, `WITH RECURSIVE descendants (id) AS (
+			SELECT id FROM animals WHERE sire_id = $1 OR dam_id = $1
+			UNION
+			SELECT c.id FROM animals c JOIN descendants d ON c.sire_id = d.id OR c.dam_id = d.id
+		) SELECT id FROM descendants WHERE id = ANY($2)`, id, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to check pedigree: %w", err)
+	}
+
+	var fields []problem.FieldError
+	for _, field := range []string{"sire_id", "dam_id"} {
+		if parent := parents[field]; parent != nil && slices.Contains(descendants, *parent) {
+			fields = append(fields, problem.FieldError{Field: field, Code: "cycle", Message: "must not be a descendant of the animal"})
+		}
+	}
+	if fields != nil {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func errSelfParent(field string) error {
+	return &ValidationError{Fields: []problem.FieldError{{Field: field, Code: "invalid", Message: "must not be the animal itself"}}}
+}
@@ -4,18 +4,208 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-var ErrAnimalNotFound = errors.New("animal not found")
+// animalColumns is the select list that matches the Animal struct. Age is not
+// stored, so it reads from relations that compute it, such as liveAnimals.
+const animalColumns = `id, name, age, birth_date, birth_date_estimated, description, species_id, sire_id, dam_id, status, enclosure_id, version, created_at, updated_at, deleted_at`
 
+// selectColumns returns the select list of a read with the given projection
+// and sort order: animalColumns, unless the projection narrows it down.
+func selectColumns(p Projection, sort []SortField) string {
+	if p.Fields == nil {
+		return animalColumns
+	}
+	return strings.Join(p.columns(sort), ", ")
+}
+
+// liveAnimals is the animals table with the age every animal has today.
+var liveAnimals = `(SELECT *, ` + ageAt("current_date") + ` AS age FROM animals) AS animals`
+
+// ageAt renders the age in whole years, at the given time, of an animal born on birth_date.
+func ageAt(when string) string {
+	return `date_part('year', age(` + when + `, birth_date))::int`
+}
+
+// birthDateFor renders the birth date estimated from an age: the same day of
+// the year, that many years ago.
+func birthDateFor(age string) string {
+	return yearsBefore("current_date", age)
+}
+
+// yearsBefore renders the date the given number of years before day.
+func yearsBefore(day, years string) string {
+	return `(` + day + ` - make_interval(years => ` + years + `))::date`
+}
+
+// keptBirthDate renders the birth date of an animal that is written with the
+// given age: the stored one while the animal still has that age, so that an
+// exact date survives a write of the age it implies, or else one estimated
+// from the age.
+func keptBirthDate(age string) string {
+	return `CASE WHEN ` + ageAt("current_date") + ` = ` + age + ` THEN birth_date ELSE ` + birthDateFor(age) + ` END`
+}
+
+// keptEstimate renders the birth_date_estimated that goes with keptBirthDate.
+func keptEstimate(age string) string {
+	return `(birth_date_estimated OR ` + ageAt("current_date") + ` <> ` + age + `)`
+}
+
+// birthDate renders the birth date of a create or update request from the
+// expressions of its birth_date and age, of which it gives at most one.
+// Without either the animal is taken to be born today.
+func birthDate(date, age string) string {
+	return `COALESCE(` + date + `, ` + birthDateFor(`COALESCE(`+age+`, 0)`) + `)`
+}
+
+// animalSource returns the relation that reads select animals from: liveAnimals,
+// or with asOf every animal as it was at that instant, rebuilt from the latest
+// revision written up to then and aged as of then. Both have the columns of
+// animals plus age, so filters, sorting and keyset pagination work the same on
+// either. A non-zero id limits the rebuild to that animal.
+func animalSource(asOf *time.Time, id int64, arg func(any) string) string {
+	if asOf == nil {
+		return liveAnimals
+	}
+
+	latest := `SELECT DISTINCT ON (animal_id) operation, snapshot FROM animal_revisions WHERE changed_at <= ` + arg(*asOf)
+	if id != 0 {
+		latest += ` AND animal_id = ` + arg(id)
+	}
+	latest += ` ORDER BY animal_id, revision DESC`
+
+	// deleted animals keep their snapshot and are filtered on deleted_at like
+	// live rows; purged ones no longer exist at all
+	return `(SELECT a.*, ` + ageAt(arg(*asOf)+"::timestamptz") + ` AS age
+		FROM (` + latest + `) r, jsonb_populate_record(NULL::animals, r.snapshot) a
+		WHERE r.operation <> '` + OpPurge + `') AS animals`
+}
+
+// Postgres error codes the repositories translate into domain errors.
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	checkViolation      = "23514"
+	exclusionViolation  = "23P01"
+)
+
+// isViolation reports whether err is a Postgres error with the given code.
+func isViolation(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+// referenceErrors maps the constraints on the references of an animal row to
+// the validation error of the field that broke them.
+var referenceErrors = map[string]error{
+	"animals_species_id_fkey":  ErrUnknownSpecies,
+	"animals_sire_id_fkey":     ErrUnknownSire,
+	"animals_dam_id_fkey":      ErrUnknownDam,
+	"animals_sire_not_self":    errSelfParent("sire_id"),
+	"animals_dam_not_self":     errSelfParent("dam_id"),
+	"animals_distinct_parents": ErrSameParents,
+}
+
+// referenceError translates a write on animals that broke one of its references
+// into a ValidationError. Other errors are wrapped with message.
+func referenceError(err error, message string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Code == foreignKeyViolation || pqErr.Code == checkViolation) {
+		if verr, ok := referenceErrors[pqErr.Constraint]; ok {
+			return verr
+		}
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// withRevision turns write, a statement on animals ending in RETURNING *, into a
+// single statement that also records a revision of every row it touches, so an
+// animal and its history can never disagree. It selects animalColumns of the
+// written rows; snapshots keep the age the animal had when it was written. A
+// purge has no new version, so its revision follows the last one.
+func withRevision(operation string, write string) string {
+	revision := "version"
+	if operation == OpPurge {
+		revision = "version + 1"
+	}
+	return `WITH changed AS (` + write + `), aged AS (
+		SELECT *, ` + ageAt("current_date") + ` AS age FROM changed
+	), revision AS (
+		INSERT INTO animal_revisions (animal_id, revision, operation, snapshot)
+		SELECT id, ` + revision + `, '` + operation + `', to_jsonb(aged) FROM aged
+	) SELECT ` + animalColumns + ` FROM aged`
+}
+
+// AnimalRepository stores animals. Every write also records a revision of the
+// written animal, see RevisionRepository.
 type AnimalRepository interface {
-	CreateAnimal(r AnimalCreateRequest) error
-	UpdateAnimal(id int64, r AnimalUpdateRequest) error
-	ListAnimals() ([]Animal, error)
+	CreateAnimal(r AnimalCreateRequest) (Animal, error)
+	// CreateAnimals inserts all animals in one statement and one transaction,
+	// returning them in the order of the requests.
+	CreateAnimals(r []AnimalCreateRequest) ([]Animal, error)
+	// CreateEachAnimal inserts the animals one by one in a transaction, undoing
+	// only the insert of an animal whose references are broken. It returns, by
+	// index of the requests, the animals created and the ValidationError of those
+	// that were not. Any other failure fails all of them.
+	CreateEachAnimal(r []AnimalCreateRequest) ([]Animal, []error, error)
+	// UpdateAnimal, PatchAnimal and DeleteAnimal only apply when the stored
+	// version equals expectedVersion, and fail with ErrVersionConflict otherwise.
+	// An expectedVersion of 0 skips the check.
+	// UpdateAnimal and PatchAnimal fail with ErrSpeciesNotAllowed when they
+	// change the species of an animal to one its enclosure does not take, and
+	// with ErrSpeciesRequired when they unset it in an enclosure that restricts
+	// species.
+	UpdateAnimal(id int64, expectedVersion int64, r AnimalUpdateRequest) (Animal, error)
+	PatchAnimal(id int64, expectedVersion int64, p AnimalPatch) (Animal, error)
+	ListAnimals(opts ListOptions) (AnimalPage, error)
 	GetAnimal(id int64) (Animal, error)
-	DeleteAnimal(id int64) error
+	// GetAnimalAsOf returns the animal as it was at the given instant. It fails
+	// with ErrAnimalNotFound when the animal did not exist or was deleted then.
+	GetAnimalAsOf(id int64, asOf time.Time) (Animal, error)
+	// GetAnimalProjected reads only the fields of the animal that p needs, as
+	// it is now or, with asOf, as it was then.
+	GetAnimalProjected(id int64, asOf *time.Time, p Projection) (Animal, error)
+	// Related loads the resources include names for all the given animals, with
+	// one query per kind of resource.
+	Related(animals []Animal, include []string) (RelatedResources, error)
+	// DeleteAnimal soft deletes an animal. It disappears from reads until it is
+	// restored, and is only removed for good by a purge.
+	DeleteAnimal(id int64, expectedVersion int64) error
+	RestoreAnimal(id int64) (Animal, error)
+	// PurgeAnimal permanently removes a soft deleted animal. It fails with
+	// ErrAnimalHasOffspring while other animals name it as their parent.
+	PurgeAnimal(id int64) error
+	// PurgeDeletedAnimals permanently removes every animal soft deleted before the
+	// given time, except for parents of animals that are still stored.
+	PurgeDeletedAnimals(deletedBefore time.Time) (int64, error)
+	// Ancestors and Descendants return the pedigree graph of an animal, reaching
+	// up to depth generations away from it.
+	Ancestors(id int64, depth int) (PedigreeGraph, error)
+	Descendants(id int64, depth int) (PedigreeGraph, error)
+	// TransitionAnimal moves an animal to req.To and records the change in its
+	// status history. It fails with ErrIllegalTransition when the lifecycle does
+	// not allow the move, with ErrNoActiveAdoption when the animal is to become
+	// adopted without an adoption that lasts today, and with ErrVersionConflict
+	// like UpdateAnimal. Taking an adopted animal back closes its adoption.
+	TransitionAnimal(id int64, expectedVersion int64, req StatusTransitionRequest) (Animal, error)
+	// StatusHistory returns the status changes of an animal, oldest first.
+	StatusHistory(id int64) ([]StatusChange, error)
+	// MoveAnimal houses an animal in the enclosure req.EnclosureID, or takes it
+	// out of its enclosure when that is nil. It fails with ErrEnclosureFull,
+	// ErrSpeciesNotAllowed or ErrNotHoused when the enclosure cannot take the
+	// animal, with ErrSpeciesRequired when the enclosure restricts species and
+	// the animal has none, and with ErrVersionConflict like UpdateAnimal.
+	MoveAnimal(id int64, expectedVersion int64, req MoveRequest) (Animal, error)
 }
 
 type PostgresAnimalRepository struct {
@@ -26,58 +216,521 @@ func NewPostgresAnimalRepository(db *sqlx.DB) *PostgresAnimalRepository {
 	return &PostgresAnimalRepository{db: db}
 }
 
-func (r *PostgresAnimalRepository) CreateAnimal(req AnimalCreateRequest) error {
-	_, err := r.db.Exec(`INSERT INTO animals (name, age, description) VALUES ($1, $2, $3)`, req.Name, req.Age, req.Description)
+// insertAnimal inserts one animal from the fields of an AnimalCreateRequest.
+var insertAnimal = withRevision(OpCreate, `INSERT INTO animals (name, birth_date, birth_date_estimated, description, species_id, sire_id, dam_id)
+	VALUES ($1, `+birthDate("$2::date", "$3::int")+`, $2::date IS NULL OR $4, $5, $6, $7, $8) RETURNING *`)
+
+func (r *PostgresAnimalRepository) CreateAnimal(req AnimalCreateRequest) (Animal, error) {
+	var animal Animal
+	err := r.db.QueryRowx(insertAnimal,
+		req.Name, req.BirthDate, req.Age, req.BirthDateEstimated, req.Description, req.SpeciesID, req.SireID, req.DamID).StructScan(&animal)
 	if err != nil {
-		return fmt.Errorf("failed to insert animal: %w", err)
+		return Animal{}, referenceError(err, "failed to insert animal")
 	}
-	return nil
+	return animal, nil
+}
+
+func (r *PostgresAnimalRepository) CreateAnimals(reqs []AnimalCreateRequest) ([]Animal, error) {
+	var (
+		ids          = make([]int64, len(reqs))
+		names        = make([]string, len(reqs))
+		birthDates   = make([]sql.NullString, len(reqs))
+		estimated    = make([]bool, len(reqs))
+		ages         = make([]sql.NullInt64, len(reqs))
+		descriptions = make([]string, len(reqs))
+		species      = make([]sql.NullInt64, len(reqs))
+		sires        = make([]sql.NullInt64, len(reqs))
+		dams         = make([]sql.NullInt64, len(reqs))
+		animals      = make([]Animal, len(reqs))
+	)
+	for i, req := range reqs {
+		names[i], descriptions[i], estimated[i] = req.Name, req.Description, req.BirthDateEstimated
+		if req.BirthDate != nil {
+			birthDates[i] = sql.NullString{String: *req.BirthDate, Valid: true}
+		}
+		if req.Age != nil {
+			ages[i] = sql.NullInt64{Int64: int64(*req.Age), Valid: true}
+		}
+		species[i], sires[i], dams[i] = nullInt64(req.SpeciesID), nullInt64(req.SireID), nullInt64(req.DamID)
+	}
+
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		// the id of every request is drawn up front, so the inserted rows can be
+		// put back in the order of the requests whatever order they come back in
+		var drawn []struct {
+			Ord int64 `db:"ord"`
+			ID  int64 `db:"id"`
+		}
+		err := tx.Select(&drawn, `SELECT r.ord, nextval(pg_get_serial_sequence('animals', 'id')) AS id
+			FROM unnest($1::text[]) WITH ORDINALITY AS r (name, ord)`, pq.Array(names))
+		if err != nil {
+			return fmt.Errorf("failed to draw animal ids: %w", err)
+		}
+		for _, d := range drawn {
+			ids[d.Ord-1] = d.ID
+		}
+
+		positions := make(map[int64]int, len(ids))
+		for i, id := range ids {
+			positions[id] = i
+		}
+
+		rows, err := tx.Queryx(withRevision(OpCreate, `INSERT INTO animals (id, name, birth_date, birth_date_estimated, description, species_id, sire_id, dam_id)
+			SELECT id, name, `+birthDate("birth_date", "age")+`, birth_date IS NULL OR estimated, description, species_id, sire_id, dam_id
+			FROM unnest($1::bigint[], $2::text[], $3::date[], $4::bool[], $5::int[], $6::text[], $7::bigint[], $8::bigint[], $9::bigint[])
+				AS r (id, name, birth_date, estimated, age, description, species_id, sire_id, dam_id)
+			RETURNING *`),
+			pq.Array(ids), pq.Array(names), pq.Array(birthDates), pq.Array(estimated), pq.Array(ages),
+			pq.Array(descriptions), pq.Array(species), pq.Array(sires), pq.Array(dams))
+		if err != nil {
+			return referenceError(err, "failed to insert animals")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var animal Animal
+			if err := rows.StructScan(&animal); err != nil {
+				return fmt.Errorf("error scanning row: %w", err)
+			}
+			animals[positions[animal.ID]] = animal
+		}
+		if err := rows.Err(); err != nil {
+			return referenceError(err, "failed to insert animals")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return animals, nil
 }
 
-func (r *PostgresAnimalRepository) UpdateAnimal(id int64, req AnimalUpdateRequest) error {
-	res, err := r.db.Exec(`UPDATE animals SET name = $1, age = $2, description = $3 WHERE id = $4`, req.Name, req.Age, req.Description, id)
+func (r *PostgresAnimalRepository) CreateEachAnimal(reqs []AnimalCreateRequest) ([]Animal, []error, error) {
+	var (
+		animals = make([]Animal, len(reqs))
+		errs    = make([]error, len(reqs))
+	)
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		for i, req := range reqs {
+			if _, err := tx.Exec(`SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("failed to set savepoint: %w", err)
+			}
+			err := tx.QueryRowx(insertAnimal,
+				req.Name, req.BirthDate, req.Age, req.BirthDateEstimated, req.Description, req.SpeciesID, req.SireID, req.DamID).StructScan(&animals[i])
+			if err == nil {
+				if _, err := tx.Exec(`RELEASE SAVEPOINT batch_item`); err != nil {
+					return fmt.Errorf("failed to release savepoint: %w", err)
+				}
+				continue
+			}
+
+			// only a broken reference is the fault of the item; anything else
+			// fails the batch
+			err = referenceError(err, "failed to insert animal")
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				return err
+			}
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			animals[i], errs[i] = Animal{}, err
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update animal: %w", err)
+		return nil, nil, err
 	}
-	rows, err := res.RowsAffected()
+	return animals, errs, nil
+}
+
+func nullInt64(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
+
+func (r *PostgresAnimalRepository) UpdateAnimal(id int64, expectedVersion int64, req AnimalUpdateRequest) (Animal, error) {
+	var (
+		animal Animal
+		args   = []any{req.Name, req.BirthDate, req.Age, req.BirthDateEstimated, req.Description, req.SpeciesID, req.SireID, req.DamID, id}
+		// without a birth date the age decides, and without either the animal
+		// keeps the age it has
+		age          = `COALESCE($3::int, ` + ageAt("current_date") + `)`
+		sqlStatement = `UPDATE animals SET name = $1, birth_date = COALESCE($2::date, ` + keptBirthDate(age) + `),
+			birth_date_estimated = CASE WHEN $2::date IS NULL THEN ` + keptEstimate(age) + ` ELSE $4 END,
+			description = $5, species_id = $6, sire_id = $7, dam_id = $8,
+			version = version + 1, updated_at = now() WHERE id = $9 AND deleted_at IS NULL`
+	)
+	if expectedVersion > 0 {
+		args = append(args, expectedVersion)
+		sqlStatement += ` AND version = $10`
+	}
+
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := checkPedigree(tx, id, req.SireID, req.DamID); err != nil {
+			return err
+		}
+		if err := tx.QueryRowx(withRevision(OpUpdate, sqlStatement+` RETURNING *`), args...).StructScan(&animal); err != nil {
+			return err
+		}
+		return keepPlace(tx, animal)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected on update: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Animal{}, r.unmatchedRowError(id, expectedVersion, nil)
+		}
+		return Animal{}, referenceError(err, "failed to update animal")
 	}
-	if rows == 0 {
-		return ErrAnimalNotFound
+	return animal, nil
+}
+
+func (r *PostgresAnimalRepository) PatchAnimal(id int64, expectedVersion int64, p AnimalPatch) (Animal, error) {
+	var (
+		animal      Animal
+		sets, where []string
+		args        = []any{id}
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	set := p.Set
+	switch {
+	case set.Age != nil:
+		// an age only tells roughly when the animal was born
+		age := arg(*set.Age)
+		sets = append(sets, "birth_date = "+keptBirthDate(age), "birth_date_estimated = "+keptEstimate(age))
+		set.BirthDateEstimated = nil
+	case set.BirthDate != nil && set.BirthDateEstimated == nil:
+		// a birth date given without the flag is exact
+		set.BirthDateEstimated = new(bool)
+	}
+	for _, f := range patchColumns(set) {
+		sets = append(sets, f.column+" = "+arg(f.value))
+	}
+	for _, f := range patchColumns(p.Test) {
+		where = append(where, f.column+" IS NOT DISTINCT FROM "+arg(f.value))
+	}
+	if p.Test.Age != nil {
+		where = append(where, ageAt("current_date")+" = "+arg(*p.Test.Age))
+	}
+	// the version moves even when only test operations were given, so that
+	// concurrent writers observe the patch as a write
+	sets = append(sets, "version = version + 1", "updated_at = now()")
+	if expectedVersion > 0 {
+		where = append(where, "version = "+arg(expectedVersion))
+	}
+
+	sqlStatement := `UPDATE animals SET ` + strings.Join(sets, ", ") + ` WHERE id = $1 AND deleted_at IS NULL`
+	if len(where) > 0 {
+		sqlStatement += " AND " + strings.Join(where, " AND ")
+	}
+	sqlStatement += " RETURNING *"
+
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := checkPedigree(tx, id, nonZero(p.Set.SireID), nonZero(p.Set.DamID)); err != nil {
+			return err
+		}
+		if err := tx.QueryRowx(withRevision(OpUpdate, sqlStatement), args...).StructScan(&animal); err != nil {
+			return err
+		}
+		if p.Set.SpeciesID == nil {
+			return nil
+		}
+		return keepPlace(tx, animal)
+	})
+	if err == nil {
+		return animal, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Animal{}, referenceError(err, "failed to patch animal")
+	}
+
+	var testErr error
+	if len(patchColumns(p.Test)) > 0 || p.Test.Age != nil {
+		testErr = ErrPatchTestFailed
+	}
+	return Animal{}, r.unmatchedRowError(id, expectedVersion, testErr)
+}
+
+// inTx runs fn in a transaction that is committed when fn succeeds and rolled back otherwise.
+func inTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
 
-func (r *PostgresAnimalRepository) ListAnimals() ([]Animal, error) {
+// unmatchedRowError explains why a conditional write on animal id matched no
+// row: the animal is gone, its version moved on, or otherwise the condition
+// described by otherErr did not hold.
+func (r *PostgresAnimalRepository) unmatchedRowError(id int64, expectedVersion int64, otherErr error) error {
+	var version int64
+	err := r.db.Get(&version, `SELECT version FROM animals WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+		}
+		return fmt.Errorf("failed to check animal: %w", err)
+	}
+	if expectedVersion > 0 && version != expectedVersion {
+		return fmt.Errorf("%w: id=%d has version %d, expected %d", ErrVersionConflict, id, version, expectedVersion)
+	}
+	if otherErr != nil {
+		return otherErr
+	}
+	return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+}
+
+type patchColumn struct {
+	column string
+	value  any
+}
+
+// patchColumns lists the columns of the fields that are present, in a fixed
+// order. Age is not a column, so it is left to the caller.
+func patchColumns(fields AnimalFields) []patchColumn {
+	var columns []patchColumn
+	if fields.Name != nil {
+		columns = append(columns, patchColumn{"name", *fields.Name})
+	}
+	if fields.BirthDate != nil {
+		columns = append(columns, patchColumn{"birth_date", *fields.BirthDate})
+	}
+	if fields.BirthDateEstimated != nil {
+		columns = append(columns, patchColumn{"birth_date_estimated", *fields.BirthDateEstimated})
+	}
+	if fields.Description != nil {
+		columns = append(columns, patchColumn{"description", *fields.Description})
+	}
+	if fields.SpeciesID != nil {
+		columns = append(columns, patchColumn{"species_id", nonZero(fields.SpeciesID)})
+	}
+	if fields.SireID != nil {
+		columns = append(columns, patchColumn{"sire_id", nonZero(fields.SireID)})
+	}
+	if fields.DamID != nil {
+		columns = append(columns, patchColumn{"dam_id", nonZero(fields.DamID)})
+	}
+	return columns
+}
+
+// nonZero returns the reference a patch field holds, or nil when it is absent
+// or 0, which stands for none.
+func nonZero(v *int64) *int64 {
+	if v == nil || *v == 0 {
+		return nil
+	}
+	return v
+}
+
+func (r *PostgresAnimalRepository) ListAnimals(opts ListOptions) (AnimalPage, error) {
 	var (
-		animals      []Animal = make([]Animal, 0)
-		sqlStatement          = `SELECT id, name, age, description FROM animals`
+		animals            []Animal = make([]Animal, 0, opts.Limit+1)
+		sqlStatement, args          = buildListQuery(opts)
 	)
 
-	rows, err := r.db.Queryx(sqlStatement)
+	rows, err := r.db.Queryx(sqlStatement, args...)
 	if err != nil {
-		return nil, fmt.Errorf("ListAnimals query error: %w", err)
+		return AnimalPage{}, fmt.Errorf("ListAnimals query error: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var animal Animal
 		if err := rows.StructScan(&animal); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
+			return AnimalPage{}, fmt.Errorf("error scanning row: %w", err)
 		}
 		animals = append(animals, animal)
 	}
-	return animals, nil
+	if err := rows.Err(); err != nil {
+		return AnimalPage{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	page := AnimalPage{Animals: animals}
+	if len(animals) > opts.Limit {
+		page.Animals = animals[:opts.Limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+// listColumns maps the field names accepted in ListOptions to SQL columns.
+// Only names present here ever reach the generated SQL. Ages are sorted by
+// birth date, which the index on birth_date serves, see sortDesc.
+var listColumns = map[string]string{
+	"id":          "id",
+	"name":        "name",
+	"age":         "birth_date",
+	"description": "description",
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// buildListQuery renders the parameterized SELECT for a list call. It fetches
+// one row more than the limit so the caller can tell whether another page follows.
+func buildListQuery(opts ListOptions) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	source := animalSource(opts.AsOf, 0, arg)
+
+	if opts.Name != nil {
+		switch opts.Name.Match {
+		case MatchPrefix:
+			where = append(where, "name ILIKE "+arg(likeEscaper.Replace(opts.Name.Value)+"%"))
+		case MatchContains:
+			where = append(where, "name ILIKE "+arg("%"+likeEscaper.Replace(opts.Name.Value)+"%"))
+		default:
+			where = append(where, "name = "+arg(opts.Name.Value))
+		}
+	}
+	// ages are compared as birth dates, so that the index on birth_date serves
+	// them: an animal is at least n years old when it was born n years ago or
+	// earlier
+	today := "current_date"
+	if opts.AsOf != nil {
+		today = "(" + arg(*opts.AsOf) + "::timestamptz)::date"
+	}
+	if opts.AgeGTE != nil {
+		where = append(where, "birth_date <= "+yearsBefore(today, arg(*opts.AgeGTE)))
+	}
+	if opts.AgeLTE != nil {
+		where = append(where, "birth_date > "+yearsBefore(today, arg(*opts.AgeLTE+1)))
+	}
+	if opts.DescriptionContains != nil {
+		where = append(where, "description ILIKE "+arg("%"+likeEscaper.Replace(*opts.DescriptionContains)+"%"))
+	}
+	if opts.UpdatedSince != nil {
+		where = append(where, "updated_at >= "+arg(*opts.UpdatedSince))
+	}
+	if opts.Taxon != nil {
+		where = append(where, "species_id IN ("+speciesSubtree(arg(*opts.Taxon))+")")
+	}
+	if len(opts.Tags) > 0 {
+		tagged := `SELECT at.animal_id FROM animal_tags at JOIN tags t ON t.id = at.tag_id WHERE t.name = ANY(` + arg(pq.Array(opts.Tags)) + `)`
+		if opts.TagMatch != TagMatchAny {
+			tagged += ` GROUP BY at.animal_id HAVING count(*) = ` + arg(len(opts.Tags))
+		}
+		where = append(where, "id IN ("+tagged+")")
+	}
+	if len(opts.Statuses) > 0 {
+		where = append(where, "status = ANY("+arg(pq.Array(opts.Statuses))+")")
+	}
+	if opts.Enclosure != nil {
+		where = append(where, "enclosure_id = "+arg(*opts.Enclosure))
+	}
+	if opts.Owner != nil {
+		where = append(where, "id IN (SELECT animal_id FROM adoptions WHERE owner_id = "+arg(*opts.Owner)+
+			" AND (returned_on IS NULL OR returned_on > current_date))")
+	}
+	if !opts.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+
+	// id is always the final sort key so that the order, and with it the cursor, is total
+	order := opts.Sort
+	if !slices.ContainsFunc(order, func(f SortField) bool { return f.Field == "id" }) {
+		order = append(order[:len(order):len(order)], SortField{Field: "id"})
+	}
+
+	if opts.After != nil {
+		where = append(where, keysetCondition(order, opts.After, arg))
+	}
+
+	orderBy := make([]string, len(order))
+	for i, f := range order {
+		orderBy[i] = listColumns[f.Field]
+		if sortDesc(f) {
+			orderBy[i] += " DESC"
+		}
+	}
+
+	query := `SELECT ` + selectColumns(opts.Projection, opts.Sort) + ` FROM ` + source
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + strings.Join(orderBy, ", ") + " LIMIT " + arg(opts.Limit+1)
+
+	return query, args
+}
+
+// sortDesc reports whether the column of a sort field is ordered descending.
+// The older an animal, the earlier its birth date, so ages sort the other way.
+func sortDesc(f SortField) bool {
+	return f.Desc != (f.Field == "age")
+}
+
+// keysetCondition renders the predicate selecting the rows that come after the
+// cursor in the given order: (a > x) OR (a = x AND b > y) OR ..., with the
+// comparison flipped for descending fields.
+func keysetCondition(order []SortField, after *Cursor, arg func(any) string) string {
+	value := func(i int) any {
+		if order[i].Field == "id" || i >= len(after.Values) {
+			return after.ID
+		}
+		return after.Values[i]
+	}
+
+	var alternatives []string
+	for i := range order {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, listColumns[order[j].Field]+" = "+arg(value(j)))
+		}
+		op := " > "
+		if sortDesc(order[i]) {
+			op = " < "
+		}
+		terms = append(terms, listColumns[order[i].Field]+op+arg(value(i)))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
 }
 
 func (r *PostgresAnimalRepository) GetAnimal(id int64) (Animal, error) {
+	return r.getAnimal(id, nil, Projection{})
+}
+
+func (r *PostgresAnimalRepository) GetAnimalAsOf(id int64, asOf time.Time) (Animal, error) {
+	return r.getAnimal(id, &asOf, Projection{})
+}
+
+func (r *PostgresAnimalRepository) GetAnimalProjected(id int64, asOf *time.Time, p Projection) (Animal, error) {
+	return r.getAnimal(id, asOf, p)
+}
+
+func (r *PostgresAnimalRepository) getAnimal(id int64, asOf *time.Time, p Projection) (Animal, error) {
 	var (
-		animal       Animal
-		sqlStatement = `SELECT * FROM animals WHERE id = $1`
+		animal Animal
+		args   = []any{id}
 	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	sqlStatement := `SELECT ` + selectColumns(p, nil) + ` FROM ` + animalSource(asOf, id, arg) + ` WHERE id = $1 AND deleted_at IS NULL`
 
-	err := r.db.QueryRowx(sqlStatement, id).StructScan(&animal)
+	err := r.db.QueryRowx(sqlStatement, args...).StructScan(&animal)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Animal{}, fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
@@ -87,8 +740,17 @@ func (r *PostgresAnimalRepository) GetAnimal(id int64) (Animal, error) {
 	return animal, nil
 }
 
-func (r *PostgresAnimalRepository) DeleteAnimal(id int64) error {
-	res, err := r.db.Exec(`DELETE FROM animals WHERE id = $1`, id)
+func (r *PostgresAnimalRepository) DeleteAnimal(id int64, expectedVersion int64) error {
+	var (
+		args         = []any{id}
+		sqlStatement = `UPDATE animals SET deleted_at = now(), enclosure_id = NULL, version = version + 1, updated_at = now() WHERE id = $1 AND deleted_at IS NULL`
+	)
+	if expectedVersion > 0 {
+		args = append(args, expectedVersion)
+		sqlStatement += ` AND version = $2`
+	}
+
+	res, err := r.db.Exec(withRevision(OpDelete, sqlStatement+` RETURNING *`), args...)
 	if err != nil {
 		return fmt.Errorf("failed to delete animal: %w", err)
 	}
@@ -97,7 +759,65 @@ func (r *PostgresAnimalRepository) DeleteAnimal(id int64) error {
 		return fmt.Errorf("failed to get rows affected on delete: %w", err)
 	}
 	if rows == 0 {
-		return ErrAnimalNotFound
+		return r.unmatchedRowError(id, expectedVersion, nil)
+	}
+	return nil
+}
+
+func (r *PostgresAnimalRepository) RestoreAnimal(id int64) (Animal, error) {
+	var animal Animal
+	err := r.db.QueryRowx(withRevision(OpRestore, `UPDATE animals SET deleted_at = NULL, version = version + 1, updated_at = now() WHERE id = $1 AND deleted_at IS NOT NULL RETURNING *`),
+		id).StructScan(&animal)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Animal{}, r.notDeletedError(id)
+		}
+		return Animal{}, fmt.Errorf("failed to restore animal: %w", err)
+	}
+	return animal, nil
+}
+
+func (r *PostgresAnimalRepository) PurgeAnimal(id int64) error {
+	res, err := r.db.Exec(withRevision(OpPurge, `DELETE FROM animals WHERE id = $1 AND deleted_at IS NOT NULL RETURNING *`), id)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			return fmt.Errorf("%w: id=%d", ErrAnimalHasOffspring, id)
+		}
+		return fmt.Errorf("failed to purge animal: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on purge: %w", err)
+	}
+	if rows == 0 {
+		return r.notDeletedError(id)
 	}
 	return nil
 }
+
+func (r *PostgresAnimalRepository) PurgeDeletedAnimals(deletedBefore time.Time) (int64, error) {
+	// parents stay until their offspring are purged, as they would break the pedigree
+	res, err := r.db.Exec(withRevision(OpPurge, `DELETE FROM animals WHERE deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM animals c WHERE c.sire_id = animals.id OR c.dam_id = animals.id)
+		RETURNING *`), deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted animals: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected on purge: %w", err)
+	}
+	return rows, nil
+}
+
+// notDeletedError explains why an operation on a soft deleted animal matched no row.
+func (r *PostgresAnimalRepository) notDeletedError(id int64) error {
+	var exists bool
+	if err := r.db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM animals WHERE id = $1)`, id); err != nil {
+		return fmt.Errorf("failed to check animal: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: id=%d", ErrAnimalNotDeleted, id)
+	}
+	return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+}
@@ -1,5 +1,7 @@
 package animal
 
+import "github.com/diegotremper/go-animals/internal/idempotency"
+
 func AddRoutes(module Module) {
 	rg := module.RouterGroup()
 	db := module.Db()
@@ -8,10 +10,90 @@ func AddRoutes(module Module) {
 
 	repo := NewPostgresAnimalRepository(db)
 	handler := NewAnimalHandler(module, repo)
+	idempotent := idempotency.Middleware(module.IdempotencyStore(), module.RootLogger())
+	// routes that send animals, in any of the formats of render
+	acceptable := requireAcceptable(module)
 
-	animals.POST("", handler.CreateAnimalHandler)
-	animals.GET("/:id", handler.GetAnimalHandler)
-	animals.GET("", handler.ListAnimalsHandler)
-	animals.PUT("/:id", handler.UpdateAnimalHandler)
+	animals.POST("", acceptable, idempotent, handler.CreateAnimalHandler)
+	animals.GET("/:id", acceptable, handler.GetAnimalHandler)
+	animals.GET("", acceptable, handler.ListAnimalsHandler)
+	animals.PUT("/:id", acceptable, handler.UpdateAnimalHandler)
+	animals.PATCH("/:id", acceptable, handler.PatchAnimalHandler)
 	animals.DELETE("/:id", handler.DeleteAnimalHandler)
+	animals.POST("/:id/restore", acceptable, handler.RestoreAnimalHandler)
+	animals.GET("/:id/ancestors", handler.AncestorsHandler)
+	animals.GET("/:id/descendants", handler.DescendantsHandler)
+	animals.GET("/:id/inbreeding", handler.InbreedingHandler)
+	animals.POST("/:id/transitions", acceptable, handler.TransitionHandler)
+	animals.GET("/:id/status-history", handler.StatusHistoryHandler)
+	animals.POST("/:id/move", acceptable, handler.MoveAnimalHandler)
+
+	history := NewHistoryHandler(module, NewPostgresRevisionRepository(db))
+	animals.GET("/:id/history", history.ListHistoryHandler)
+	animals.GET("/:id/history/diff", history.DiffHistoryHandler)
+	animals.GET("/:id/history/:rev", history.GetRevisionHandler)
+
+	attachments := NewAttachmentHandler(module, NewPostgresAttachmentRepository(db), repo, module.BlobStore())
+	animals.POST("/:id/attachments", attachments.UploadAttachmentHandler)
+	animals.GET("/:id/attachments", attachments.ListAttachmentsHandler)
+	animals.GET("/:id/attachments/:attachment_id", attachments.DownloadAttachmentHandler)
+	animals.DELETE("/:id/attachments/:attachment_id", attachments.DeleteAttachmentHandler)
+
+	tags := NewTagHandler(module, NewPostgresTagRepository(db), repo)
+	animals.GET("/:id/tags", tags.ListAnimalTagsHandler)
+	animals.POST("/:id/tags", tags.AddTagsHandler)
+	animals.DELETE("/:id/tags/:tag", tags.RemoveTagHandler)
+	rg.GET("/tags", tags.ListTagsHandler)
+
+	medical := NewMedicalRecordHandler(module, NewPostgresMedicalRecordRepository(db), repo)
+	animals.POST("/:id/medical-records", medical.CreateMedicalRecordHandler)
+	animals.GET("/:id/medical-records", medical.ListMedicalRecordsHandler)
+	animals.GET("/:id/medical-records/:record_id", medical.GetMedicalRecordHandler)
+	animals.PUT("/:id/medical-records/:record_id", medical.UpdateMedicalRecordHandler)
+	animals.DELETE("/:id/medical-records/:record_id", medical.DeleteMedicalRecordHandler)
+	rg.GET("/vaccinations/due", medical.DueVaccinationsHandler)
+
+	adoptions := NewAdoptionHandler(module, NewPostgresAdoptionRepository(db), repo)
+	animals.POST("/:id/adoptions", adoptions.CreateAdoptionHandler)
+	animals.GET("/:id/adoptions", adoptions.ListAdoptionsHandler)
+	animals.GET("/:id/adoptions/:adoption_id", adoptions.GetAdoptionHandler)
+	animals.PUT("/:id/adoptions/:adoption_id", adoptions.UpdateAdoptionHandler)
+	animals.DELETE("/:id/adoptions/:adoption_id", adoptions.DeleteAdoptionHandler)
+
+	owners := NewOwnerHandler(module, NewPostgresOwnerRepository(db), repo)
+	ownersGroup := rg.Group("/owners")
+	ownersGroup.POST("", owners.CreateOwnerHandler)
+	ownersGroup.GET("", owners.ListOwnersHandler)
+	ownersGroup.GET("/:id", owners.GetOwnerHandler)
+	ownersGroup.PUT("/:id", owners.UpdateOwnerHandler)
+	ownersGroup.DELETE("/:id", owners.DeleteOwnerHandler)
+	ownersGroup.GET("/:id/animals", acceptable, owners.ListOwnerAnimalsHandler)
+
+	enclosures := NewEnclosureHandler(module, NewPostgresEnclosureRepository(db), repo)
+	enclosuresGroup := rg.Group("/enclosures")
+	enclosuresGroup.POST("", enclosures.CreateEnclosureHandler)
+	enclosuresGroup.GET("", enclosures.ListEnclosuresHandler)
+	enclosuresGroup.GET("/occupancy", enclosures.OccupancyHandler)
+	enclosuresGroup.GET("/:id", enclosures.GetEnclosureHandler)
+	enclosuresGroup.PUT("/:id", enclosures.UpdateEnclosureHandler)
+	enclosuresGroup.DELETE("/:id", enclosures.DeleteEnclosureHandler)
+	enclosuresGroup.GET("/:id/animals", acceptable, enclosures.ListEnclosureAnimalsHandler)
+
+	species := NewSpeciesHandler(module, NewPostgresSpeciesRepository(db), repo)
+	speciesGroup := rg.Group("/species")
+	speciesGroup.POST("", species.CreateSpeciesHandler)
+	speciesGroup.GET("", species.ListSpeciesHandler)
+	speciesGroup.GET("/:id", species.GetSpeciesHandler)
+	speciesGroup.PUT("/:id", species.UpdateSpeciesHandler)
+	speciesGroup.DELETE("/:id", species.DeleteSpeciesHandler)
+	speciesGroup.GET("/:id/lineage", species.LineageHandler)
+	speciesGroup.GET("/:id/animals", acceptable, species.ListSpeciesAnimalsHandler)
+
+	// custom methods such as POST /animals:batch
+	rg.POST("/animals:action", acceptable, idempotent, handler.CollectionActionHandler)
+
+	adminAnimals := module.AdminRouterGroup().Group("/animals")
+	adminAnimals.GET("", acceptable, handler.AdminListAnimalsHandler)
+	adminAnimals.DELETE("/:id", handler.PurgeAnimalHandler)
+	adminAnimals.DELETE("", handler.PurgeDeletedAnimalsHandler)
 }
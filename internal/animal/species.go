@@ -0,0 +1,198 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// speciesListParams are the query parameters accepted by GET /species.
+var speciesListParams = map[string]bool{
+	"limit":     true,
+	"cursor":    true,
+	"rank":      true,
+	"parent_id": true,
+}
+
+type SpeciesHandler struct {
+	module  Module
+	repo    SpeciesRepository
+	animals AnimalRepository
+}
+
+func NewSpeciesHandler(module Module, repo SpeciesRepository, animals AnimalRepository) *SpeciesHandler {
+	return &SpeciesHandler{module: module, repo: repo, animals: animals}
+}
+
+func (h *SpeciesHandler) CreateSpeciesHandler(ctx *gin.Context) {
+	var req SpeciesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	species, err := h.repo.CreateSpecies(req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", fmt.Sprintf("/species/%d", species.ID))
+	ctx.JSON(http.StatusCreated, species)
+}
+
+func (h *SpeciesHandler) UpdateSpeciesHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req SpeciesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	species, err := h.repo.UpdateSpecies(id, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, species)
+}
+
+func (h *SpeciesHandler) ListSpeciesHandler(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	for param := range query {
+		if !speciesListParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parsePageParams(ctx, nil)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts := SpeciesListOptions{Limit: limit}
+	if after != nil {
+		opts.AfterID = after.ID
+	}
+
+	if query.Has("rank") {
+		rank := query.Get("rank")
+		if !slices.Contains(SpeciesRanks, rank) {
+			h.respondError(ctx, badRequest("rank must be one of %v", SpeciesRanks))
+			return
+		}
+		opts.Rank = &rank
+	}
+	if query.Has("parent_id") {
+		parentID, err := strconv.ParseInt(query.Get("parent_id"), 10, 64)
+		if err != nil {
+			h.respondError(ctx, badRequest("parent_id must be a species id"))
+			return
+		}
+		opts.ParentID = &parentID
+	}
+
+	page, err := h.repo.ListSpecies(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if page.HasMore {
+		token := EncodeCursor(Cursor{ID: page.Species[len(page.Species)-1].ID})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, SpeciesListResponse{Data: page.Species, NextCursor: nextCursor})
+}
+
+func (h *SpeciesHandler) GetSpeciesHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	species, err := h.repo.GetSpecies(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, species)
+}
+
+// LineageHandler returns the taxa from the kingdom down to the requested one.
+func (h *SpeciesHandler) LineageHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	lineage, err := h.repo.Lineage(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": lineage})
+}
+
+func (h *SpeciesHandler) DeleteSpeciesHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if err := h.repo.DeleteSpecies(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Species deleted successfully"})
+}
+
+// ListSpeciesAnimalsHandler lists the animals of a taxon and of every taxon
+// below it, so a family lists the animals of all its genera and species. It
+// accepts the query parameters of GET /animals.
+func (h *SpeciesHandler) ListSpeciesAnimalsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetSpecies(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx, false)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	opts.Taxon = &id
+
+	page, err := h.animals.ListAnimals(opts)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	writeAnimalPage(ctx, h.module, h.animals, opts, page)
+}
@@ -0,0 +1,223 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// speciesColumns is the select list that matches the Species struct.
+const speciesColumns = `id, parent_id, rank, scientific_name, common_names, created_at, updated_at`
+
+type SpeciesRepository interface {
+	// CreateSpecies and UpdateSpecies fail with a ValidationError when the taxon
+	// would not sit below its parent and above its children.
+	CreateSpecies(r SpeciesRequest) (Species, error)
+	UpdateSpecies(id int64, r SpeciesRequest) (Species, error)
+	ListSpecies(opts SpeciesListOptions) (SpeciesPage, error)
+	GetSpecies(id int64) (Species, error)
+	// Lineage returns the taxon and its ancestors, from the kingdom down.
+	Lineage(id int64) ([]Species, error)
+	// DeleteSpecies fails with ErrSpeciesInUse while taxa, animals or enclosures
+	// reference the taxon.
+	DeleteSpecies(id int64) error
+}
+
+type PostgresSpeciesRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresSpeciesRepository(db *sqlx.DB) *PostgresSpeciesRepository {
+	return &PostgresSpeciesRepository{db: db}
+}
+
+// speciesSubtree renders a query of the ids of the taxon given by the placeholder
+// and of every taxon below it.
+func speciesSubtree(taxon string) string {
+	return `WITH RECURSIVE subtree AS (
+		SELECT id FROM species WHERE id = ` + taxon + `
+		UNION ALL
+		SELECT s.id FROM species s JOIN subtree t ON s.parent_id = t.id
+	) SELECT id FROM subtree`
+}
+
+func (r *PostgresSpeciesRepository) CreateSpecies(req SpeciesRequest) (Species, error) {
+	var species Species
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := checkHierarchy(tx, 0, req); err != nil {
+			return err
+		}
+		return tx.QueryRowx(`INSERT INTO species (parent_id, rank, scientific_name, common_names) VALUES ($1, $2, $3, $4) RETURNING `+speciesColumns,
+			req.ParentID, req.Rank, req.ScientificName, commonNames(req)).StructScan(&species)
+	})
+	if err != nil {
+		return Species{}, speciesWriteError(err, req, "failed to insert species")
+	}
+	return species, nil
+}
+
+func (r *PostgresSpeciesRepository) UpdateSpecies(id int64, req SpeciesRequest) (Species, error) {
+	var species Species
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := checkHierarchy(tx, id, req); err != nil {
+			return err
+		}
+		return tx.QueryRowx(`UPDATE species SET parent_id = $1, rank = $2, scientific_name = $3, common_names = $4, updated_at = now()
+			WHERE id = $5 RETURNING `+speciesColumns,
+			req.ParentID, req.Rank, req.ScientificName, commonNames(req), id).StructScan(&species)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Species{}, fmt.Errorf("%w: id=%d", ErrSpeciesNotFound, id)
+		}
+		return Species{}, speciesWriteError(err, req, "failed to update species")
+	}
+	return species, nil
+}
+
+// checkHierarchy makes sure taxon id, 0 for a new one, ranks strictly below its
+// parent and above its children. Since ranks only decrease downwards, this also
+// keeps the hierarchy free of cycles.
+func checkHierarchy(tx *sqlx.Tx, id int64, req SpeciesRequest) error {
+	rank := slices.Index(SpeciesRanks, req.Rank)
+
+	switch {
+	case req.ParentID == nil && rank > 0:
+		return &ValidationError{Fields: []problem.FieldError{{Field: "parent_id", Code: "required", Message: "is required below kingdom"}}}
+	case req.ParentID != nil && rank == 0:
+		return &ValidationError{Fields: []problem.FieldError{{Field: "parent_id", Code: "invalid", Message: "must be empty for a kingdom"}}}
+	case req.ParentID != nil && *req.ParentID == id:
+		return &ValidationError{Fields: []problem.FieldError{{Field: "parent_id", Code: "invalid", Message: "must not be the taxon itself"}}}
+	case req.ParentID != nil:
+		var parentRank string
+		err := tx.Get(&parentRank, `SELECT rank FROM species WHERE id = $1 FOR SHARE`, *req.ParentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &ValidationError{Fields: []problem.FieldError{{Field: "parent_id", Code: "unknown", Message: "does not reference an existing species"}}}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check parent species: %w", err)
+		}
+		if slices.Index(SpeciesRanks, parentRank) >= rank {
+			return &ValidationError{Fields: []problem.FieldError{{Field: "parent_id", Code: "invalid_rank", Message: fmt.Sprintf("must be a taxon ranked above %s, not a %s", req.Rank, parentRank)}}}
+		}
+	}
+
+	if id == 0 {
+		return nil
+	}
+	var childRanks []string
+	if err := tx.Select(&childRanks, `SELECT DISTINCT rank FROM species WHERE parent_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to check child species: %w", err)
+	}
+	for _, child := range childRanks {
+		if slices.Index(SpeciesRanks, child) <= rank {
+			return &ValidationError{Fields: []problem.FieldError{{Field: "rank", Code: "invalid_rank", Message: fmt.Sprintf("must rank above its children, which include a %s", child)}}}
+		}
+	}
+	return nil
+}
+
+// speciesWriteError translates a failed species write into a domain error.
+func speciesWriteError(err error, req SpeciesRequest, message string) error {
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return err
+	case isViolation(err, uniqueViolation):
+		return fmt.Errorf("%w: a species named %q already exists", ErrConflict, req.ScientificName)
+	default:
+		return fmt.Errorf("%s: %w", message, err)
+	}
+}
+
+// commonNames returns the common names to store, which are never NULL.
+func commonNames(req SpeciesRequest) any {
+	if req.CommonNames == nil {
+		return pq.Array([]string{})
+	}
+	return pq.Array(req.CommonNames)
+}
+
+func (r *PostgresSpeciesRepository) ListSpecies(opts SpeciesListOptions) (SpeciesPage, error) {
+	var (
+		species []Species
+		where   = []string{"id > $1"}
+		args    = []any{opts.AfterID}
+	)
+	if opts.Rank != nil {
+		args = append(args, *opts.Rank)
+		where = append(where, fmt.Sprintf("rank = $%d", len(args)))
+	}
+	if opts.ParentID != nil {
+		args = append(args, *opts.ParentID)
+		where = append(where, fmt.Sprintf("parent_id = $%d", len(args)))
+	}
+	args = append(args, opts.Limit+1)
+
+	err := r.db.Select(&species, `SELECT `+speciesColumns+` FROM species WHERE `+strings.Join(where, " AND ")+
+		fmt.Sprintf(` ORDER BY id LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return SpeciesPage{}, fmt.Errorf("ListSpecies query error: %w", err)
+	}
+
+	page := SpeciesPage{Species: species}
+	if page.Species == nil {
+		page.Species = []Species{}
+	}
+	if len(species) > opts.Limit {
+		page.Species, page.HasMore = species[:opts.Limit], true
+	}
+	return page, nil
+}
+
+func (r *PostgresSpeciesRepository) GetSpecies(id int64) (Species, error) {
+	var species Species
+	err := r.db.Get(&species, `SELECT `+speciesColumns+` FROM species WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Species{}, fmt.Errorf("%w: id=%d", ErrSpeciesNotFound, id)
+		}
+		return Species{}, fmt.Errorf("error getting species: %w", err)
+	}
+	return species, nil
+}
+
+func (r *PostgresSpeciesRepository) Lineage(id int64) ([]Species, error) {
+	var lineage []Species
+	err := r.db.Select(&lineage, `WITH RECURSIVE lineage AS (
+			SELECT *, 0 AS depth FROM species WHERE id = $1
+			UNION ALL
+			SELECT s.*, l.depth + 1 FROM species s JOIN lineage l ON s.id = l.parent_id
+		) SELECT `+speciesColumns+` FROM lineage ORDER BY depth DESC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("Lineage query error: %w", err)
+	}
+	if len(lineage) == 0 {
+		return nil, fmt.Errorf("%w: id=%d", ErrSpeciesNotFound, id)
+	}
+	return lineage, nil
+}
+
+func (r *PostgresSpeciesRepository) DeleteSpecies(id int64) error {
+	res, err := r.db.Exec(`DELETE FROM species WHERE id = $1`, id)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			return fmt.Errorf("%w: id=%d", ErrSpeciesInUse, id)
+		}
+		return fmt.Errorf("failed to delete species: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: id=%d", ErrSpeciesNotFound, id)
+	}
+	return nil
+}
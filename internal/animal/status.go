@@ -0,0 +1,111 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Lifecycle statuses of an animal. New animals start at intake.
+const (
+	StatusIntake     = "intake"
+	StatusQuarantine = "quarantine"
+	StatusAvailable  = "available"
+	StatusAdopted    = "adopted"
+	StatusDeceased   = "deceased"
+)
+
+// Statuses lists every status in lifecycle order.
+var Statuses = []string{StatusIntake, StatusQuarantine, StatusAvailable, StatusAdopted, StatusDeceased}
+
+// unhousedStatuses are the statuses of animals that are no longer kept in an
+// enclosure.
+var unhousedStatuses = []string{StatusAdopted, StatusDeceased}
+
+// statusTransitions is the lifecycle of an animal: for each status, the statuses
+// it may move to next. It is the only place transitions are defined; deceased
+// is terminal.
+var statusTransitions = map[string][]string{
+	StatusIntake:     {StatusQuarantine, StatusAvailable, StatusDeceased},
+	StatusQuarantine: {StatusAvailable, StatusDeceased},
+	StatusAvailable:  {StatusQuarantine, StatusAdopted, StatusDeceased},
+	StatusAdopted:    {StatusQuarantine, StatusAvailable, StatusDeceased},
+	StatusDeceased:   {},
+}
+
+// CanTransition reports whether an animal may move from one status to another.
+func CanTransition(from, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// TransitionHandler moves an animal to another status. Transitions the
+// lifecycle does not allow fail with 409. Like other writes it honours If-Match.
+func (h *AnimalHandler) TransitionHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req StatusTransitionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+	if _, ok := statusTransitions[req.To]; !ok {
+		h.respondError(ctx, &ValidationError{Fields: []problem.FieldError{{
+			Field: "to", Code: "invalid", Message: "must be one of " + strings.Join(Statuses, ", "),
+		}}})
+		return
+	}
+
+	animal, err := h.repo.TransitionAnimal(id, version, req)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	setAnimalETag(ctx, animal)
+	render(ctx, h.module, http.StatusOK, animalDocument(Projection{}), animal)
+}
+
+// StatusHistoryHandler lists the status changes of an animal, oldest first.
+func (h *AnimalHandler) StatusHistoryHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.repo.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	changes, err := h.repo.StatusHistory(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": changes})
+}
+
+// illegalTransition explains why an animal cannot move from one status to another.
+func illegalTransition(id int64, from, to string) error {
+	if len(statusTransitions[from]) == 0 {
+		return fmt.Errorf("%w: animal %d is %s, which is final", ErrIllegalTransition, id, from)
+	}
+	return fmt.Errorf("%w: animal %d cannot go from %s to %s, only to %s",
+		ErrIllegalTransition, id, from, to, strings.Join(statusTransitions[from], ", "))
+}
@@ -0,0 +1,92 @@
+package animal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func (r *PostgresAnimalRepository) TransitionAnimal(id int64, expectedVersion int64, req StatusTransitionRequest) (Animal, error) {
+	var animal Animal
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		// the row lock keeps the status from changing between the check and the update
+		var current Animal
+		err := tx.Get(&current, `SELECT `+animalColumns+` FROM `+liveAnimals+` WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, id)
+			}
+			return fmt.Errorf("error getting animal: %w", err)
+		}
+		if expectedVersion > 0 && current.Version != expectedVersion {
+			return fmt.Errorf("%w: id=%d", ErrVersionConflict, id)
+		}
+		if !CanTransition(current.Status, req.To) {
+			return illegalTransition(id, current.Status, req.To)
+		}
+		if err := settleAdoption(tx, current, req.To); err != nil {
+			return err
+		}
+
+		// adopted and deceased animals leave their enclosure, freeing the place
+		err = tx.QueryRowx(withRevision(OpTransition, `UPDATE animals SET status = $2,
+			enclosure_id = CASE WHEN $2 = ANY($3) THEN NULL ELSE enclosure_id END,
+			version = version + 1, updated_at = now()
+			WHERE id = $1 RETURNING *`), id, req.To, pq.Array(unhousedStatuses)).StructScan(&animal)
+		if err != nil {
+			return fmt.Errorf("failed to update animal status: %w", err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO animal_status_changes (animal_id, from_status, to_status, reason, changed_at)
+			VALUES ($1, $2, $3, $4, $5)`, id, current.Status, req.To, req.Reason, animal.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record status change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Animal{}, err
+	}
+	return animal, nil
+}
+
+// activeAdoption restricts a query on adoptions to those that last today.
+const activeAdoption = `adopted_on <= current_date AND (returned_on IS NULL OR returned_on > current_date)`
+
+// settleAdoption keeps the status of an animal in line with its adoptions. It
+// may only become adopted while an adoption of it lasts, which stays locked
+// until the transition commits. An adopted animal that comes back to the
+// shelter was returned, which closes its adoption today.
+func settleAdoption(tx *sqlx.Tx, current Animal, to string) error {
+	switch {
+	case to == StatusAdopted:
+		var adoptionID int64
+		err := tx.Get(&adoptionID, `SELECT id FROM adoptions WHERE animal_id = $1 AND `+activeAdoption+` LIMIT 1 FOR SHARE`, current.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: id=%d", ErrNoActiveAdoption, current.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check adoptions: %w", err)
+		}
+	case current.Status == StatusAdopted && to != StatusDeceased:
+		_, err := tx.Exec(`UPDATE adoptions SET returned_on = current_date, updated_at = now()
+			WHERE animal_id = $1 AND `+activeAdoption, current.ID)
+		if err != nil {
+			return fmt.Errorf("failed to close adoption: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *PostgresAnimalRepository) StatusHistory(id int64) ([]StatusChange, error) {
+	changes := []StatusChange{}
+	err := r.db.Select(&changes, `SELECT id, animal_id, from_status, to_status, reason, changed_at
+		FROM animal_status_changes WHERE animal_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("StatusHistory query error: %w", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,102 @@
+package animal
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// TagRepository keeps the tags of animals. Tags are created the first time an
+// animal is tagged with them.
+type TagRepository interface {
+	// AnimalTags returns the names of the tags of an animal, in alphabetical order.
+	AnimalTags(animalID int64) ([]string, error)
+	// AddTags tags an animal and returns all of its tags. Tags the animal already
+	// carries are left alone. It fails with ErrAnimalNotFound when the animal is gone.
+	AddTags(animalID int64, names []string) ([]string, error)
+	// RemoveTag fails with ErrTagNotFound when the animal does not carry the tag.
+	RemoveTag(animalID int64, name string) ([]string, error)
+	ListTags(afterID int64, limit int) (TagPage, error)
+}
+
+type PostgresTagRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresTagRepository(db *sqlx.DB) *PostgresTagRepository {
+	return &PostgresTagRepository{db: db}
+}
+
+func (r *PostgresTagRepository) AnimalTags(animalID int64) ([]string, error) {
+	return animalTags(r.db, animalID)
+}
+
+func animalTags(q sqlx.Queryer, animalID int64) ([]string, error) {
+	names := []string{}
+	err := sqlx.Select(q, &names, `SELECT t.name FROM animal_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.animal_id = $1 ORDER BY t.name`, animalID)
+	if err != nil {
+		return nil, fmt.Errorf("AnimalTags query error: %w", err)
+	}
+	return names, nil
+}
+
+func (r *PostgresTagRepository) AddTags(animalID int64, names []string) ([]string, error) {
+	var tags []string
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		// a separate statement, so that tags created concurrently by another
+		// transaction are visible when linking them below
+		if _, err := tx.Exec(`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, pq.Array(names)); err != nil {
+			return fmt.Errorf("failed to insert tags: %w", err)
+		}
+		_, err := tx.Exec(`INSERT INTO animal_tags (animal_id, tag_id)
+			SELECT $1, id FROM tags WHERE name = ANY($2) ON CONFLICT DO NOTHING`, animalID, pq.Array(names))
+		if err != nil {
+			if isViolation(err, foreignKeyViolation) {
+				return fmt.Errorf("%w: id=%d", ErrAnimalNotFound, animalID)
+			}
+			return fmt.Errorf("failed to tag animal: %w", err)
+		}
+
+		tags, err = animalTags(tx, animalID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *PostgresTagRepository) RemoveTag(animalID int64, name string) ([]string, error) {
+	res, err := r.db.Exec(`DELETE FROM animal_tags WHERE animal_id = $1
+		AND tag_id = (SELECT id FROM tags WHERE name = $2)`, animalID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove tag: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected on delete: %w", err)
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("%w: animal %d is not tagged %q", ErrTagNotFound, animalID, name)
+	}
+	return animalTags(r.db, animalID)
+}
+
+func (r *PostgresTagRepository) ListTags(afterID int64, limit int) (TagPage, error) {
+	tags := []Tag{}
+	err := r.db.Select(&tags, `SELECT t.id, t.name, count(a.id) AS count FROM tags t
+		LEFT JOIN animal_tags at ON at.tag_id = t.id
+		LEFT JOIN animals a ON a.id = at.animal_id AND a.deleted_at IS NULL
+		WHERE t.id > $1 GROUP BY t.id ORDER BY t.id LIMIT $2`, afterID, limit+1)
+	if err != nil {
+		return TagPage{}, fmt.Errorf("ListTags query error: %w", err)
+	}
+
+	page := TagPage{Tags: tags}
+	if len(tags) > limit {
+		page.Tags, page.HasMore = tags[:limit], true
+	}
+	return page, nil
+}
@@ -0,0 +1,175 @@
+package animal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// tagListParams are the query parameters accepted by GET /tags.
+var tagListParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+}
+
+type TagHandler struct {
+	module  Module
+	repo    TagRepository
+	animals AnimalRepository
+}
+
+func NewTagHandler(module Module, repo TagRepository, animals AnimalRepository) *TagHandler {
+	return &TagHandler{module: module, repo: repo, animals: animals}
+}
+
+// normalizeTag folds a tag to the form it is stored in, so "Featured" and
+// "featured" are the same tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// validTag reports whether a normalized tag only has lowercase letters, digits,
+// "-" and "_", starting with a letter or digit.
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > 50 {
+		return false
+	}
+	for i, c := range tag {
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case (c == '-' || c == '_') && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (h *TagHandler) ListAnimalTagsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	tags, err := h.repo.AnimalTags(id)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// AddTagsHandler tags an animal and responds with all of its tags.
+func (h *TagHandler) AddTagsHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var req TagsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		h.respondError(ctx, bindingError(err))
+		return
+	}
+
+	var (
+		names   []string
+		invalid []problem.FieldError
+	)
+	for i, tag := range req.Tags {
+		name := normalizeTag(tag)
+		if !validTag(name) {
+			invalid = append(invalid, problem.FieldError{
+				Field:   fmt.Sprintf("tags[%d]", i),
+				Code:    "invalid",
+				Message: "must only contain letters, digits, - and _, starting with a letter or digit",
+			})
+		}
+		names = append(names, name)
+	}
+	if invalid != nil {
+		h.respondError(ctx, &ValidationError{Fields: invalid})
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	tags, err := h.repo.AddTags(id, names)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// RemoveTagHandler untags an animal and responds with the tags it has left.
+func (h *TagHandler) RemoveTagHandler(ctx *gin.Context) {
+	id, err := parseID(ctx)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	if _, err := h.animals.GetAnimal(id); err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	tags, err := h.repo.RemoveTag(id, normalizeTag(ctx.Param("tag")))
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// ListTagsHandler lists every tag with the number of animals that carry it.
+func (h *TagHandler) ListTagsHandler(ctx *gin.Context) {
+	for param := range ctx.Request.URL.Query() {
+		if !tagListParams[param] {
+			h.respondError(ctx, badRequest("unknown query parameter %q", param))
+			return
+		}
+	}
+
+	limit, after, err := parsePageParams(ctx, nil)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+	var afterID int64
+	if after != nil {
+		afterID = after.ID
+	}
+
+	page, err := h.repo.ListTags(afterID, limit)
+	if err != nil {
+		h.respondError(ctx, err)
+		return
+	}
+
+	var nextCursor *string
+	if page.HasMore {
+		token := EncodeCursor(Cursor{ID: page.Tags[len(page.Tags)-1].ID})
+		nextCursor = &token
+	}
+
+	ctx.Header("Link", pageLinks(ctx, limit, nextCursor))
+	ctx.JSON(http.StatusOK, TagListResponse{Data: page.Tags, NextCursor: nextCursor})
+}
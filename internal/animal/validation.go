@@ -0,0 +1,185 @@
+package animal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationError lists every input field that failed validation.
+type ValidationError struct {
+	Fields []problem.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(parts, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+func init() {
+	// report fields by their JSON name, which is what clients send
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("notfuture", notFuture)
+	}
+}
+
+// notFuture validates that a date in DateLayout is not after today, in UTC.
+// Dates that do not parse are left to the datetime rule.
+func notFuture(fl validator.FieldLevel) bool {
+	d, err := ParseDate(fl.Field().String())
+	return err != nil || !d.After(time.Now().UTC())
+}
+
+// snakeCase turns the name of a Go field, as validation rules refer to other
+// fields, into the name of its JSON member.
+func snakeCase(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bindingError converts an error returned by gin's binding into a domain error.
+// Field level problems become a ValidationError, malformed bodies a bad request.
+func bindingError(err error) error {
+	var (
+		verrs   validator.ValidationErrors
+		typeErr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &verrs):
+		fields := make([]problem.FieldError, len(verrs))
+		for i, fe := range verrs {
+			fields[i] = fieldError(fe)
+		}
+		return &ValidationError{Fields: fields}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &ValidationError{Fields: []problem.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("must be a %s", jsonTypeName(typeErr.Type)),
+		}}}
+	default:
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+}
+
+// validatePatch applies the rules of AnimalUpdateRequest to the fields a patch writes.
+func validatePatch(p AnimalPatch) error {
+	var (
+		req    AnimalUpdateRequest
+		fields []string
+	)
+	if p.Set.Name != nil {
+		req.Name, fields = *p.Set.Name, append(fields, "Name")
+	}
+	if p.Set.Age != nil {
+		req.Age, fields = p.Set.Age, append(fields, "Age")
+	}
+	if p.Set.BirthDate != nil {
+		req.BirthDate, fields = p.Set.BirthDate, append(fields, "BirthDate")
+	}
+	if p.Set.Description != nil {
+		req.Description, fields = *p.Set.Description, append(fields, "Description")
+	}
+	if p.Set.SpeciesID != nil && *p.Set.SpeciesID != 0 {
+		req.SpeciesID, fields = p.Set.SpeciesID, append(fields, "SpeciesID")
+	}
+	if p.Set.SireID != nil && *p.Set.SireID != 0 {
+		req.SireID, fields = p.Set.SireID, append(fields, "SireID")
+	}
+	if p.Set.DamID != nil && *p.Set.DamID != 0 {
+		req.DamID, fields = p.Set.DamID, append(fields, "DamID")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+	if err := v.StructPartial(req, fields...); err != nil {
+		return bindingError(err)
+	}
+	return nil
+}
+
+func fieldError(fe validator.FieldError) problem.FieldError {
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return problem.FieldError{Field: fe.Field(), Code: "required", Message: "is required"}
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return problem.FieldError{Field: fe.Field(), Code: "too_long", Message: fmt.Sprintf("must have at most %s items", fe.Param())}
+		}
+		if isString {
+			return problem.FieldError{Field: fe.Field(), Code: "too_long", Message: fmt.Sprintf("must be at most %s characters long", fe.Param())}
+		}
+		return problem.FieldError{Field: fe.Field(), Code: "too_large", Message: fmt.Sprintf("must be at most %s", fe.Param())}
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return problem.FieldError{Field: fe.Field(), Code: "too_short", Message: fmt.Sprintf("must have at least %s items", fe.Param())}
+		}
+		if isString {
+			return problem.FieldError{Field: fe.Field(), Code: "too_short", Message: fmt.Sprintf("must be at least %s characters long", fe.Param())}
+		}
+		return problem.FieldError{Field: fe.Field(), Code: "too_small", Message: fmt.Sprintf("must be at least %s", fe.Param())}
+	case "email":
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: "must be an email address"}
+	case "datetime":
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: "must be formatted as " + fe.Param()}
+	case "notfuture":
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: "must not be in the future"}
+	case "excluded_with":
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: "must not be given together with " + snakeCase(fe.Param())}
+	case "oneof":
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")}
+	default:
+		return problem.FieldError{Field: fe.Field(), Code: "invalid", Message: fmt.Sprintf("failed the %q rule", fe.Tag())}
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Bool:
+		return "boolean"
+	default:
+		return t.Kind().String()
+	}
+}
@@ -0,0 +1,19 @@
+// Package blob stores opaque binary objects, such as uploaded files, by key.
+package blob
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under slash separated keys such as "animals/12/3f9a".
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any previous blob.
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. It fails with ErrNotFound when there is none.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(key string) error
+}
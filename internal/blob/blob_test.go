@@ -0,0 +1,123 @@
+package blob_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/diegotremper/go-animals/internal/blob"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 keeps objects in memory and answers like S3 does, for signed requests only.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeChunks(body)
+		}
+		f.objects[r.URL.Path] = string(body)
+		w.Header().Set("ETag", `"1"`)
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Last-Modified", "Thu, 02 Jan 2025 03:04:05 GMT")
+		io.WriteString(w, object)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeChunks reads the content of a body sent with aws-chunked encoding,
+// leaving the signatures of the chunks unchecked.
+func decodeChunks(body []byte) []byte {
+	var content []byte
+	for {
+		header, rest, _ := bytes.Cut(body, []byte("\r\n"))
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			return content
+		}
+		content = append(content, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+func TestStores(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string]string{}})
+	defer server.Close()
+
+	s3, err := blob.NewS3Store(blob.S3Config{
+		Endpoint: server.URL, Region: "us-east-1", Bucket: "animals", AccessKeyID: "key", SecretAccessKey: "secret",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	stores := map[string]blob.Store{
+		"file": blob.NewFileStore(t.TempDir()),
+		"s3":   s3,
+	}
+	for name, store := range stores {
+		assert.NoError(t, store.Put("animals/1/photo", strings.NewReader("content"), 7, "image/png"), name)
+
+		r, err := store.Get("animals/1/photo")
+		if assert.NoError(t, err, name) {
+			content, _ := io.ReadAll(r)
+			r.Close()
+			assert.Equal(t, "content", string(content), name)
+		}
+
+		assert.NoError(t, store.Delete("animals/1/photo"), name)
+		assert.NoError(t, store.Delete("animals/1/photo"), name)
+		_, err = store.Get("animals/1/photo")
+		assert.ErrorIs(t, err, blob.ErrNotFound, name)
+	}
+}
+
+func TestNewS3Store_InvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "s3.amazonaws.com", "ftp://s3.amazonaws.com"} {
+		_, err := blob.NewS3Store(blob.S3Config{Endpoint: endpoint, Region: "us-east-1", Bucket: "animals"})
+
+		assert.Error(t, err, endpoint)
+	}
+}
+
+func TestFileStore_InvalidKey(t *testing.T) {
+	store := blob.NewFileStore(t.TempDir())
+
+	assert.Error(t, store.Put("../outside", strings.NewReader("x"), 1, "text/plain"))
+	_, err := store.Get("/etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestFileStore_ShortWrite(t *testing.T) {
+	store := blob.NewFileStore(t.TempDir())
+
+	assert.Error(t, store.Put("animals/1/photo", strings.NewReader("short"), 10, "image/png"))
+	_, err := store.Get("animals/1/photo")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
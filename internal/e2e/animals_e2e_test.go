@@ -8,6 +8,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/diegotremper/go-animals/infrastructure"
 	"github.com/diegotremper/go-animals/internal/animal"
+	"github.com/diegotremper/go-animals/internal/blob"
 
 	"github.com/docker/go-connections/nat"
 	"github.com/gin-gonic/gin"
@@ -31,7 +33,8 @@ import (
 
 func startTestServer(db *sql.DB) (*http.Server, string, func(context.Context) error, error) {
 	gin.SetMode(gin.TestMode)
-	r := infrastructure.SetupRouter(infrastructure.InitLogger(), sqlx.NewDb(db, "postgres"))
+	dbx := sqlx.NewDb(db, "postgres")
+	r := infrastructure.SetupRouter(infrastructure.InitLogger(), dbx, infrastructure.InitIdempotencyStore(dbx), blob.NewFileStore(os.TempDir()))
 	// Use dynamic port
 	ln, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -68,6 +71,36 @@ func applyMigrations(db *sql.DB, t *testing.T) {
 	}
 }
 
+// startPostgres runs a migrated Postgres in a container for the duration of t.
+func startPostgres(t *testing.T) *sql.DB {
+	ctx := context.Background()
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15.2",
+		postgres.WithDatabase("animals"),
+		postgres.WithUsername("test"),
+		postgres.WithPassword("test"),
+		testcontainers.WithWaitStrategy(
+			wait.ForSQL("5432", "postgres", func(host string, port nat.Port) string {
+				return fmt.Sprintf("host=%s port=%s user=test password=test dbname=animals sslmode=disable", host, port.Port())
+			}).WithStartupTimeout(30*time.Second),
+		),
+	)
+	if err != nil {
+		t.Fatalf("could not start container: %v", err)
+	}
+	t.Cleanup(func() { pgContainer.Terminate(ctx) })
+
+	connStr := pgContainer.MustConnectionString(ctx, "sslmode=disable")
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	applyMigrations(db, t)
+	return db
+}
+
 func createAnimal(t *testing.T, baseURL string) {
 	resp, err := http.Post(baseURL+"/animals", "application/json",
 		strings.NewReader(`{"name":"E2ETest","age":5,"description":"e2e check"}`))
@@ -75,8 +108,11 @@ func createAnimal(t *testing.T, baseURL string) {
 		t.Fatalf("post failed: %v", err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Location"), "/animals/") {
+		t.Fatalf("expected Location header, got %q", resp.Header.Get("Location"))
 	}
 }
 
@@ -88,10 +124,11 @@ func listAnimals(t *testing.T, baseURL string) {
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 
-	var animals []animal.Animal
-	if err := json.Unmarshal(body, &animals); err != nil {
+	var page animal.AnimalListResponse
+	if err := json.Unmarshal(body, &page); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
+	animals := page.Data
 	if len(animals) == 0 {
 		t.Fatal("expected at least one animal")
 	}
@@ -135,6 +172,7 @@ func deleteAnimal(t *testing.T, baseURL string, id int64) {
 	if err != nil {
 		t.Fatalf("create delete req failed: %v", err)
 	}
+	req.Header.Set("If-Match", "*")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("delete failed: %v", err)
@@ -145,43 +183,49 @@ func deleteAnimal(t *testing.T, baseURL string, id int64) {
 	}
 }
 
-func assertAnimalDeleted(t *testing.T, db *sql.DB, id int64) {
-	var count int
-	row := db.QueryRow("SELECT COUNT(*) FROM animals WHERE id = $1", id)
-	if err := row.Scan(&count); err != nil {
-		t.Fatalf("failed count check: %v", err)
+func assertAnimalDeleted(t *testing.T, db *sql.DB, baseURL string, id int64) {
+	var deleted bool
+	row := db.QueryRow("SELECT deleted_at IS NOT NULL FROM animals WHERE id = $1", id)
+	if err := row.Scan(&deleted); err != nil {
+		t.Fatalf("failed deleted check: %v", err)
 	}
-	if count != 0 {
-		t.Fatalf("expected 0 records, got %d", count)
+	if !deleted {
+		t.Fatal("expected animal to be soft deleted")
 	}
-}
 
-func TestE2E_AnimalsLifecycle(t *testing.T) {
-	ctx := context.Background()
-	pgContainer, err := postgres.Run(ctx,
-		"postgres:15.2",
-		postgres.WithDatabase("animals"),
-		postgres.WithUsername("test"),
-		postgres.WithPassword("test"),
-		testcontainers.WithWaitStrategy(
-			wait.ForSQL("5432", "postgres", func(host string, port nat.Port) string {
-				return fmt.Sprintf("host=%s port=%s user=test password=test dbname=animals sslmode=disable", host, port.Port())
-			}).WithStartupTimeout(30*time.Second),
-		),
-	)
+	resp, err := http.Get(fmt.Sprintf("%s/animals/%d", baseURL, id))
 	if err != nil {
-		t.Fatalf("could not start container: %v", err)
+		t.Fatalf("get by id failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted animal, got %d", resp.StatusCode)
 	}
-	defer pgContainer.Terminate(ctx)
+}
 
-	connStr := pgContainer.MustConnectionString(ctx, "sslmode=disable")
-	db, err := sql.Open("postgres", connStr)
+func assertHistory(t *testing.T, baseURL string, id int64) {
+	resp, err := http.Get(fmt.Sprintf("%s/animals/%d/history", baseURL, id))
 	if err != nil {
-		t.Fatalf("failed to connect: %v", err)
+		t.Fatalf("get history failed: %v", err)
 	}
-	defer db.Close()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
 
-	applyMigrations(db, t)
+	var page animal.RevisionListResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Data) != 2 || page.Data[0].Operation != animal.OpCreate || page.Data[1].Operation != animal.OpDelete {
+		t.Fatalf("expected create and delete revisions, got %+v", page.Data)
+	}
+	if page.Data[0].Snapshot.Name != "E2ETest" || page.Data[1].Snapshot.DeletedAt == nil {
+		t.Fatalf("unexpected snapshots: %+v", page.Data)
+	}
+}
+
+func TestE2E_AnimalsLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := startPostgres(t)
 
 	_, baseURL, shutdown, err := startTestServer(db)
 	if err != nil {
@@ -204,6 +248,9 @@ func TestE2E_AnimalsLifecycle(t *testing.T) {
 		deleteAnimal(t, baseURL, createdID)
 	})
 	t.Run("ensure animal is gone", func(t *testing.T) {
-		assertAnimalDeleted(t, db, createdID)
+		assertAnimalDeleted(t, db, baseURL, createdID)
+	})
+	t.Run("history keeps every revision", func(t *testing.T) {
+		assertHistory(t, baseURL, createdID)
 	})
 }
@@ -0,0 +1,190 @@
+package e2e_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/diegotremper/go-animals/internal/animal"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func newSpecies(t *testing.T, repo animal.SpeciesRepository, parentID *int64, rank, name string) animal.Species {
+	s, err := repo.CreateSpecies(animal.SpeciesRequest{ParentID: parentID, Rank: rank, ScientificName: name})
+	if err != nil {
+		t.Fatalf("failed to create species %s: %v", name, err)
+	}
+	return s
+}
+
+func newAnimal(t *testing.T, repo animal.AnimalRepository, name string, speciesID *int64) animal.Animal {
+	a, err := repo.CreateAnimal(animal.AnimalCreateRequest{Name: name, SpeciesID: speciesID})
+	if err != nil {
+		t.Fatalf("failed to create animal %s: %v", name, err)
+	}
+	return a
+}
+
+func animalIDs(animals []animal.Animal) []int64 {
+	ids := make([]int64, len(animals))
+	for i, a := range animals {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func TestSpeciesRepository_Hierarchy(t *testing.T) {
+	db := sqlx.NewDb(startPostgres(t), "postgres")
+	species := animal.NewPostgresSpeciesRepository(db)
+	animals := animal.NewPostgresAnimalRepository(db)
+
+	kingdom := newSpecies(t, species, nil, "kingdom", "Testimalia")
+	felidae := newSpecies(t, species, &kingdom.ID, "family", "Testidae")
+	cat := newSpecies(t, species, &felidae.ID, "species", "Testis catus")
+	canidae := newSpecies(t, species, &kingdom.ID, "family", "Testinidae")
+
+	t.Run("lineage runs from the kingdom down", func(t *testing.T) {
+		lineage, err := species.Lineage(cat.ID)
+		if err != nil {
+			t.Fatalf("lineage failed: %v", err)
+		}
+		names := make([]string, len(lineage))
+		for i, s := range lineage {
+			names[i] = s.ScientificName
+		}
+		if !slices.Equal(names, []string{"Testimalia", "Testidae", "Testis catus"}) {
+			t.Fatalf("unexpected lineage: %v", names)
+		}
+	})
+
+	tom := newAnimal(t, animals, "Tom", &cat.ID)
+	rex := newAnimal(t, animals, "Rex", &canidae.ID)
+
+	t.Run("taxon filter takes every taxon below it", func(t *testing.T) {
+		cases := []struct {
+			taxon int64
+			want  []int64
+		}{
+			{kingdom.ID, []int64{tom.ID, rex.ID}},
+			{felidae.ID, []int64{tom.ID}},
+			{cat.ID, []int64{tom.ID}},
+			{canidae.ID, []int64{rex.ID}},
+		}
+		for _, c := range cases {
+			page, err := animals.ListAnimals(animal.ListOptions{Limit: 10, Taxon: &c.taxon})
+			if err != nil {
+				t.Fatalf("list by taxon %d failed: %v", c.taxon, err)
+			}
+			if got := animalIDs(page.Animals); !slices.Equal(got, c.want) {
+				t.Errorf("taxon %d: expected animals %v, got %v", c.taxon, c.want, got)
+			}
+		}
+	})
+}
+
+func TestAdoptionRepository_NoOverlap(t *testing.T) {
+	db := sqlx.NewDb(startPostgres(t), "postgres")
+	adoptions := animal.NewPostgresAdoptionRepository(db)
+
+	owner, err := animal.NewPostgresOwnerRepository(db).CreateOwner(animal.OwnerRequest{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+	cat := newAnimal(t, animal.NewPostgresAnimalRepository(db), "Tom", nil)
+	returned := "2025-03-01"
+	if _, err := adoptions.CreateAdoption(cat.ID, animal.AdoptionRequest{OwnerID: owner.ID, AdoptedOn: "2025-01-01", ReturnedOn: &returned}); err != nil {
+		t.Fatalf("failed to create adoption: %v", err)
+	}
+
+	cases := []struct {
+		adoptedOn  string
+		returnedOn *string
+		overlaps   bool
+	}{
+		{"2025-02-01", nil, true},
+		{"2024-12-01", &returned, true},
+		// the day of the return is free again
+		{"2025-03-01", nil, false},
+	}
+	for _, c := range cases {
+		_, err := adoptions.CreateAdoption(cat.ID, animal.AdoptionRequest{OwnerID: owner.ID, AdoptedOn: c.adoptedOn, ReturnedOn: c.returnedOn})
+		if c.overlaps && !errors.Is(err, animal.ErrAlreadyAdopted) {
+			t.Errorf("adoption on %s: expected ErrAlreadyAdopted, got %v", c.adoptedOn, err)
+		}
+		if !c.overlaps && err != nil {
+			t.Errorf("adoption on %s: expected no error, got %v", c.adoptedOn, err)
+		}
+	}
+}
+
+func TestEnclosureRepository_Placement(t *testing.T) {
+	db := sqlx.NewDb(startPostgres(t), "postgres")
+	species := animal.NewPostgresSpeciesRepository(db)
+	animals := animal.NewPostgresAnimalRepository(db)
+	enclosures := animal.NewPostgresEnclosureRepository(db)
+
+	kingdom := newSpecies(t, species, nil, "kingdom", "Testimalia")
+	felidae := newSpecies(t, species, &kingdom.ID, "family", "Testidae")
+	cat := newSpecies(t, species, &felidae.ID, "species", "Testis catus")
+	canidae := newSpecies(t, species, &kingdom.ID, "family", "Testinidae")
+
+	t.Run("concurrent moves do not overfill an enclosure", func(t *testing.T) {
+		den, err := enclosures.CreateEnclosure(animal.EnclosureRequest{Name: "Den", Capacity: 1})
+		if err != nil {
+			t.Fatalf("failed to create enclosure: %v", err)
+		}
+		movers := []animal.Animal{newAnimal(t, animals, "Tom", nil), newAnimal(t, animals, "Rex", nil)}
+
+		errs := make([]error, len(movers))
+		var wg sync.WaitGroup
+		for i, a := range movers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = animals.MoveAnimal(a.ID, 0, animal.MoveRequest{EnclosureID: &den.ID})
+			}()
+		}
+		wg.Wait()
+
+		moved, full := 0, 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				moved++
+			case errors.Is(err, animal.ErrEnclosureFull):
+				full++
+			default:
+				t.Fatalf("unexpected move error: %v", err)
+			}
+		}
+		if moved != 1 || full != 1 {
+			t.Fatalf("expected one move and one full enclosure, got %d moved and %d full", moved, full)
+		}
+	})
+
+	t.Run("allowed species take every taxon below them", func(t *testing.T) {
+		cattery, err := enclosures.CreateEnclosure(animal.EnclosureRequest{Name: "Cattery", Capacity: 5, AllowedSpecies: []int64{felidae.ID}})
+		if err != nil {
+			t.Fatalf("failed to create enclosure: %v", err)
+		}
+		tom := newAnimal(t, animals, "Tom", &cat.ID)
+		rex := newAnimal(t, animals, "Rex", &canidae.ID)
+
+		if _, err := animals.MoveAnimal(tom.ID, 0, animal.MoveRequest{EnclosureID: &cattery.ID}); err != nil {
+			t.Fatalf("expected the cat to move in, got %v", err)
+		}
+		if _, err := animals.MoveAnimal(rex.ID, 0, animal.MoveRequest{EnclosureID: &cattery.ID}); !errors.Is(err, animal.ErrSpeciesNotAllowed) {
+			t.Fatalf("expected ErrSpeciesNotAllowed for the dog, got %v", err)
+		}
+		_, err = animals.UpdateAnimal(tom.ID, 0, animal.AnimalUpdateRequest{Name: "Tom", SpeciesID: &canidae.ID})
+		if !errors.Is(err, animal.ErrSpeciesNotAllowed) {
+			t.Fatalf("expected ErrSpeciesNotAllowed when the cat becomes a dog, got %v", err)
+		}
+		stray := newAnimal(t, animals, "Stray", nil)
+		if _, err := animals.MoveAnimal(stray.ID, 0, animal.MoveRequest{EnclosureID: &cattery.ID}); !errors.Is(err, animal.ErrSpeciesRequired) {
+			t.Fatalf("expected ErrSpeciesRequired for an animal without species, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package idempotency
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunCleanup deletes expired keys every interval until ctx is done.
+func RunCleanup(ctx context.Context, store Store, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := store.DeleteExpired()
+			if err != nil {
+				logger.Error("failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Info("deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/diegotremper/go-animals/internal/problem"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	KeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses that were replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+	MaxKeyLength   = 255
+	// MaxBodySize is the largest request body the middleware reads to
+	// fingerprint a request, enough for a full batch of animals.
+	MaxBodySize = 8 << 20
+)
+
+// replayedHeaders are the response headers stored along with the body.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+// Middleware makes the wrapped handler idempotent for requests that carry an
+// Idempotency-Key header. The first response for a key is stored and replayed
+// for retries with the same payload; reusing the key for another payload is
+// rejected with 422 and a retry while the first request runs with 409. Bodies
+// larger than MaxBodySize are rejected with 413.
+// Server errors, panics and responses that fail to be stored are not kept, so
+// the client can retry them with the same key.
+func Middleware(store Store, logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(KeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > MaxKeyLength {
+			problem.Write(ctx, problem.New(problem.TypeBadRequest, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters long"))
+			return
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, MaxBodySize)
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				problem.Write(ctx, problem.New(problem.TypePayloadTooLarge, http.StatusRequestEntityTooLarge, fmt.Sprintf("the request body must be at most %d bytes", MaxBodySize)))
+				return
+			}
+			problem.Write(ctx, problem.New(problem.TypeBadRequest, http.StatusBadRequest, "failed to read the request body"))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := ctx.Request.Method + " " + ctx.Request.URL.Path
+		fp := fingerprint(ctx.Request, body)
+		record, err := store.Reserve(scope, key, fp)
+		if err != nil {
+			logger.Error("failed to reserve idempotency key", "error", err)
+			problem.Write(ctx, problem.New(problem.TypeInternal, http.StatusInternalServerError, "internal server error"))
+			return
+		}
+
+		if record != nil {
+			switch {
+			case record.Fingerprint != fp:
+				problem.Write(ctx, problem.New(problem.TypeValidation, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request"))
+			case record.Response == nil:
+				problem.Write(ctx, problem.New(problem.TypeConflict, http.StatusConflict, "a request with this Idempotency-Key is still being processed"))
+			default:
+				replay(ctx, *record.Response)
+			}
+			return
+		}
+
+		// the key is released unless the response gets stored, also when the
+		// handler panics, so that the client can retry
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(scope, key); err != nil {
+				logger.Error("failed to release idempotency key", "error", err)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = rec
+		ctx.Next()
+
+		if rec.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		resp := Response{Status: rec.Status(), Header: http.Header{}, Body: rec.body.Bytes()}
+		for _, name := range replayedHeaders {
+			if v := rec.Header().Values(name); len(v) > 0 {
+				resp.Header[name] = v
+			}
+		}
+		if err := store.Complete(scope, key, resp); err != nil {
+			logger.Error("failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// fingerprint identifies the payload of a request: its query string, the
+// representation it accepts and its body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Header.Get("Accept")))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(ctx *gin.Context, resp Response) {
+	for name, values := range resp.Header {
+		for _, v := range values {
+			ctx.Writer.Header().Add(name, v)
+		}
+	}
+	ctx.Header(ReplayedHeader, "true")
+	ctx.Status(resp.Status)
+	ctx.Writer.Write(resp.Body)
+	ctx.Abort()
+}
+
+// recorder keeps a copy of the response body while it is written.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,197 @@
+package idempotency_test
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diegotremper/go-animals/internal/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	records map[string]*idempotency.Record
+	// failComplete makes Complete fail without storing the response
+	failComplete bool
+}
+
+func (s *memoryStore) Reserve(scope, key, fingerprint string) (*idempotency.Record, error) {
+	if r, ok := s.records[scope+key]; ok {
+		return r, nil
+	}
+	s.records[scope+key] = &idempotency.Record{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *memoryStore) Complete(scope, key string, resp idempotency.Response) error {
+	if s.failComplete {
+		return errors.New("connection reset")
+	}
+	s.records[scope+key].Response = &resp
+	return nil
+}
+
+func (s *memoryStore) Release(scope, key string) error {
+	delete(s.records, scope+key)
+	return nil
+}
+
+func (s *memoryStore) DeleteExpired() (int64, error) {
+	return 0, nil
+}
+
+func setupRouter(store idempotency.Store, status *int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.POST("/animals", idempotency.Middleware(store, slog.Default()), func(ctx *gin.Context) {
+		calls++
+		ctx.Header("Location", fmt.Sprintf("/animals/%d", calls))
+		ctx.JSON(*status, gin.H{"id": calls})
+	})
+	return r, &calls
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/animals", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.KeyHeader, key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_ReplaysStoredResponse(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := setupRouter(&memoryStore{records: map[string]*idempotency.Record{}}, &status)
+
+	first := post(r, "k1", `{"name":"Cat"}`)
+	retry := post(r, "k1", `{"name":"Cat"}`)
+
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "/animals/1", retry.Header().Get("Location"))
+	assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+	assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+}
+
+func TestMiddleware_DifferentPayload(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := setupRouter(&memoryStore{records: map[string]*idempotency.Record{}}, &status)
+
+	post(r, "k1", `{"name":"Cat"}`)
+	w := post(r, "k1", `{"name":"Dog"}`)
+
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var retry *httptest.ResponseRecorder
+	r := gin.New()
+	r.POST("/animals", idempotency.Middleware(&memoryStore{records: map[string]*idempotency.Record{}}, slog.Default()), func(ctx *gin.Context) {
+		if retry == nil {
+			// the client retries while the first request is still running
+			retry = post(r, "k1", `{"name":"Cat"}`)
+		}
+		ctx.Status(http.StatusCreated)
+	})
+
+	w := post(r, "k1", `{"name":"Cat"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusConflict, retry.Code)
+}
+
+func TestMiddleware_ServerErrorsAreNotStored(t *testing.T) {
+	status := http.StatusInternalServerError
+	r, calls := setupRouter(&memoryStore{records: map[string]*idempotency.Record{}}, &status)
+
+	post(r, "k1", `{"name":"Cat"}`)
+	status = http.StatusCreated
+	w := post(r, "k1", `{"name":"Cat"}`)
+
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestMiddleware_WithoutKey(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := setupRouter(&memoryStore{records: map[string]*idempotency.Record{}}, &status)
+
+	post(r, "", `{"name":"Cat"}`)
+	post(r, "", `{"name":"Cat"}`)
+
+	assert.Equal(t, 2, *calls)
+}
+
+func TestMiddleware_PanicsAreNotStored(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryStore{records: map[string]*idempotency.Record{}}
+	calls := 0
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/animals", idempotency.Middleware(store, slog.Default()), func(ctx *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		ctx.Status(http.StatusCreated)
+	})
+
+	first := post(r, "k1", `{"name":"Cat"}`)
+	w := post(r, "k1", `{"name":"Cat"}`)
+
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestMiddleware_FailedCompleteReleasesKey(t *testing.T) {
+	status := http.StatusCreated
+	store := &memoryStore{records: map[string]*idempotency.Record{}, failComplete: true}
+	r, calls := setupRouter(store, &status)
+
+	post(r, "k1", `{"name":"Cat"}`)
+	store.failComplete = false
+	w := post(r, "k1", `{"name":"Cat"}`)
+
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(idempotency.ReplayedHeader))
+}
+
+func TestMiddleware_DifferentAccept(t *testing.T) {
+	status := http.StatusCreated
+	r, calls := setupRouter(&memoryStore{records: map[string]*idempotency.Record{}}, &status)
+
+	post(r, "k1", `{"name":"Cat"}`)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/animals", strings.NewReader(`{"name":"Cat"}`))
+	req.Header.Set(idempotency.KeyHeader, "k1")
+	req.Header.Set("Accept", "text/csv")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestMiddleware_BodyTooLarge(t *testing.T) {
+	status := http.StatusCreated
+	store := &memoryStore{records: map[string]*idempotency.Record{}}
+	r, calls := setupRouter(store, &status)
+
+	w := post(r, "k1", strings.Repeat("x", idempotency.MaxBodySize+1))
+
+	assert.Equal(t, 0, *calls)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(t, store.records)
+}
@@ -0,0 +1,131 @@
+// Package idempotency lets clients safely retry non-idempotent requests by
+// sending an Idempotency-Key header. The first response for a key is stored
+// and replayed for every retry with the same key and payload.
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Response is a stored response that is replayed for retries.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a store knows about a key. Response is nil while the first
+// request with the key is still being processed.
+type Record struct {
+	Fingerprint string
+	Response    *Response
+}
+
+type Store interface {
+	// Reserve claims key within scope for a request with the given fingerprint.
+	// It returns nil when the key was free, or the existing record otherwise.
+	Reserve(scope, key, fingerprint string) (*Record, error)
+	// Complete stores the response of the request that reserved the key.
+	Complete(scope, key string, resp Response) error
+	// Release frees a reserved key without storing a response, so it can be retried.
+	Release(scope, key string) error
+	// DeleteExpired removes every key older than the store's TTL.
+	DeleteExpired() (int64, error)
+}
+
+type PostgresStore struct {
+	db  *sqlx.DB
+	ttl time.Duration
+}
+
+// NewPostgresStore returns a store that keeps keys for ttl. Expired keys are
+// treated as free even before DeleteExpired removes them.
+func NewPostgresStore(db *sqlx.DB, ttl time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, ttl: ttl}
+}
+
+func (s *PostgresStore) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *PostgresStore) Reserve(scope, key, fingerprint string) (*Record, error) {
+	res, err := s.db.Exec(`INSERT INTO idempotency_keys (scope, key, fingerprint) VALUES ($1, $2, $3)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL, created_at = now()
+		WHERE idempotency_keys.created_at < now() - make_interval(secs => $4)`,
+		scope, key, fingerprint, s.ttl.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected on reserve: %w", err)
+	}
+	if rows > 0 {
+		return nil, nil
+	}
+
+	var row struct {
+		Fingerprint string        `db:"fingerprint"`
+		Status      sql.NullInt64 `db:"status"`
+		Headers     []byte        `db:"headers"`
+		Body        []byte        `db:"body"`
+	}
+	err = s.db.Get(&row, `SELECT fingerprint, status, headers, body FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// released between the insert and the select; the caller sees it as in progress
+			return &Record{Fingerprint: fingerprint}, nil
+		}
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	record := &Record{Fingerprint: row.Fingerprint}
+	if row.Status.Valid {
+		record.Response = &Response{Status: int(row.Status.Int64), Body: row.Body}
+		if err := json.Unmarshal(row.Headers, &record.Response.Header); err != nil {
+			return nil, fmt.Errorf("failed to decode stored headers: %w", err)
+		}
+	}
+	return record, nil
+}
+
+func (s *PostgresStore) Complete(scope, key string, resp Response) error {
+	headers, err := json.Marshal(resp.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	_, err = s.db.Exec(`UPDATE idempotency_keys SET status = $3, headers = $4, body = $5 WHERE scope = $1 AND key = $2`,
+		scope, key, resp.Status, headers, resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(scope, key string) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status IS NULL`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteExpired() (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < now() - make_interval(secs => $1)`, s.ttl.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected on cleanup: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,97 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json).
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// RequestIDHeader is the request header whose value is echoed in the request_id member.
+const RequestIDHeader = "X-Request-Id"
+
+// Problem types. They are relative URI references, resolved against the API base URL.
+const (
+	TypeBadRequest           = "/problems/bad-request"
+	TypeUnauthorized         = "/problems/unauthorized"
+	TypeForbidden            = "/problems/forbidden"
+	TypeNotFound             = "/problems/not-found"
+	TypeMethodNotAllowed     = "/problems/method-not-allowed"
+	TypeNotAcceptable        = "/problems/not-acceptable"
+	TypeConflict             = "/problems/conflict"
+	TypePreconditionFailed   = "/problems/precondition-failed"
+	TypePreconditionRequired = "/problems/precondition-required"
+	TypeUnsupportedMediaType = "/problems/unsupported-media-type"
+	TypePayloadTooLarge      = "/problems/payload-too-large"
+	TypeValidation           = "/problems/validation-error"
+	TypeInternal             = "/problems/internal-error"
+)
+
+// Problem is an RFC 7807 problem details object. RequestID and Errors are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// New returns a problem of the given type and status, titled after the status code.
+func New(problemType string, status int, detail string) Problem {
+	return Problem{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Write sends p as the response and aborts the handler chain. The instance and
+// request_id members are filled from the current request when they are empty.
+func Write(ctx *gin.Context, p Problem) {
+	if ctx.Request != nil {
+		if p.Instance == "" {
+			p.Instance = ctx.Request.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = ctx.GetHeader(RequestIDHeader)
+		}
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		ctx.AbortWithStatus(p.Status)
+		return
+	}
+	ctx.Data(p.Status, ContentType, body)
+	ctx.Abort()
+}
+
+// NoRoute answers requests for unknown paths.
+func NoRoute(ctx *gin.Context) {
+	Write(ctx, New(TypeNotFound, http.StatusNotFound, "no resource matches the requested path"))
+}
+
+// NoMethod answers requests whose method is not registered for the path.
+func NoMethod(ctx *gin.Context) {
+	Write(ctx, New(TypeMethodNotAllowed, http.StatusMethodNotAllowed, "the method is not allowed for the requested resource"))
+}
+
+// Recovery answers requests whose handler panicked. It is meant for gin.CustomRecovery,
+// which has already logged the panic.
+func Recovery(ctx *gin.Context, _ any) {
+	Write(ctx, New(TypeInternal, http.StatusInternalServerError, "internal server error"))
+}
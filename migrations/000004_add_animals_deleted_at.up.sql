@@ -0,0 +1,3 @@
+ALTER TABLE animals ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS animals_deleted_at_idx ON animals (deleted_at) WHERE deleted_at IS NOT NULL;
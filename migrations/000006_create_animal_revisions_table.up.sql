@@ -0,0 +1,17 @@
+-- animal_id has no foreign key: the history of purged animals is kept for audits
+CREATE TABLE IF NOT EXISTS animal_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    animal_id BIGINT NOT NULL,
+    revision BIGINT NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'restore', 'purge')),
+    snapshot JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (animal_id, revision)
+);
+
+-- existing animals start their history with their current state
+INSERT INTO animal_revisions (animal_id, revision, operation, snapshot, changed_at)
+SELECT id, version,
+       CASE WHEN deleted_at IS NOT NULL THEN 'delete' WHEN version = 1 THEN 'create' ELSE 'update' END,
+       to_jsonb(animals), updated_at
+FROM animals;
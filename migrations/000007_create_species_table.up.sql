@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS species (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    parent_id BIGINT REFERENCES species (id),
+    rank TEXT NOT NULL CHECK (rank IN ('kingdom', 'phylum', 'class', 'order', 'family', 'genus', 'species')),
+    scientific_name TEXT NOT NULL,
+    common_names TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- "Canis familiaris" and "canis familiaris" are the same taxon
+CREATE UNIQUE INDEX IF NOT EXISTS species_scientific_name_idx ON species (lower(scientific_name));
+CREATE INDEX IF NOT EXISTS species_parent_id_idx ON species (parent_id);
+
+ALTER TABLE animals ADD COLUMN IF NOT EXISTS species_id BIGINT REFERENCES species (id);
+
+CREATE INDEX IF NOT EXISTS animals_species_id_idx ON animals (species_id);
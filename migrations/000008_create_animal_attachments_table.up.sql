@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS animal_attachments (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    animal_id BIGINT NOT NULL REFERENCES animals (id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    checksum TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS animal_attachments_animal_id_idx ON animal_attachments (animal_id, id);
+
+-- The file of a deleted attachment, including those removed along with a purged
+-- animal, is queued here until the blob store has deleted it as well.
+CREATE TABLE IF NOT EXISTS attachment_blob_deletions (
+    storage_key TEXT NOT NULL PRIMARY KEY,
+    queued_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_blob_deletion() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO attachment_blob_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS animal_attachments_queue_blob_deletion ON animal_attachments;
+CREATE TRIGGER animal_attachments_queue_blob_deletion
+    AFTER DELETE ON animal_attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_attachment_blob_deletion();
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS animal_tags (
+    animal_id BIGINT NOT NULL REFERENCES animals (id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (animal_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS animal_tags_tag_id_idx ON animal_tags (tag_id, animal_id);
@@ -0,0 +1,22 @@
+ALTER TABLE animals
+    ADD COLUMN IF NOT EXISTS sire_id BIGINT CONSTRAINT animals_sire_id_fkey REFERENCES animals (id),
+    ADD COLUMN IF NOT EXISTS dam_id BIGINT CONSTRAINT animals_dam_id_fkey REFERENCES animals (id);
+
+-- cycles through more than one animal are prevented by the application, see checkPedigree;
+-- ADD CONSTRAINT has no IF NOT EXISTS, so each one is only added when it is missing
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'animals'::regclass AND conname = 'animals_sire_not_self') THEN
+        ALTER TABLE animals ADD CONSTRAINT animals_sire_not_self CHECK (sire_id <> id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'animals'::regclass AND conname = 'animals_dam_not_self') THEN
+        ALTER TABLE animals ADD CONSTRAINT animals_dam_not_self CHECK (dam_id <> id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'animals'::regclass AND conname = 'animals_distinct_parents') THEN
+        ALTER TABLE animals ADD CONSTRAINT animals_distinct_parents CHECK (sire_id <> dam_id);
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS animals_sire_id_idx ON animals (sire_id);
+CREATE INDEX IF NOT EXISTS animals_dam_id_idx ON animals (dam_id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS medical_records (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    animal_id BIGINT NOT NULL REFERENCES animals (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('diagnosis', 'treatment', 'vaccination')),
+    title TEXT NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    administered_on DATE NOT NULL,
+    due_on DATE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT medical_records_due_after_administered CHECK (due_on >= administered_on)
+);
+
+CREATE INDEX IF NOT EXISTS medical_records_animal_id_idx ON medical_records (animal_id, administered_on, id);
+
+-- GET /vaccinations/due scans vaccinations by due date, and looks up later doses
+-- of the same vaccine to skip the ones they superseded
+CREATE INDEX IF NOT EXISTS medical_records_due_on_idx ON medical_records (due_on, id) WHERE kind = 'vaccination';
+CREATE INDEX IF NOT EXISTS medical_records_vaccine_idx ON medical_records (animal_id, lower(title), administered_on) WHERE kind = 'vaccination';
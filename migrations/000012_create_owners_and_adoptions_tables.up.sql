@@ -0,0 +1,36 @@
+-- btree_gist lets the exclusion constraint below compare animal ids with =
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS owners (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT,
+    phone TEXT,
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS owners_email_idx ON owners (lower(email));
+
+-- An adoption lasts from adopted_on until the day the animal was returned, or
+-- for good while returned_on is null. The periods of an animal never overlap.
+CREATE TABLE IF NOT EXISTS adoptions (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    animal_id BIGINT NOT NULL REFERENCES animals (id) ON DELETE CASCADE,
+    owner_id BIGINT NOT NULL,
+    adopted_on DATE NOT NULL,
+    returned_on DATE,
+    fee_cents BIGINT NOT NULL DEFAULT 0 CHECK (fee_cents >= 0),
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT adoptions_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES owners (id),
+    CONSTRAINT adoptions_returned_after_adopted CHECK (returned_on >= adopted_on),
+    CONSTRAINT adoptions_no_overlap EXCLUDE USING gist (
+        animal_id WITH =,
+        daterange(adopted_on, returned_on, '[)') WITH &&
+    )
+);
+
+CREATE INDEX IF NOT EXISTS adoptions_owner_id_idx ON adoptions (owner_id);
@@ -0,0 +1,33 @@
+-- animals that are already in the shelter are up for adoption; new ones start at intake
+ALTER TABLE animals ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'available'
+    CONSTRAINT animals_status_check CHECK (status IN ('intake', 'quarantine', 'available', 'adopted', 'deceased'));
+ALTER TABLE animals ALTER COLUMN status SET DEFAULT 'intake';
+
+-- except the ones an adoption covers today, which are with their owner
+UPDATE animals a SET status = 'adopted'
+WHERE status = 'available' AND EXISTS (
+    SELECT 1 FROM adoptions d
+    WHERE d.animal_id = a.id AND d.adopted_on <= current_date
+        AND (d.returned_on IS NULL OR d.returned_on > current_date)
+);
+
+CREATE INDEX IF NOT EXISTS animals_status_idx ON animals (status, id);
+
+-- snapshots taken before the column existed get the status their animal was given
+UPDATE animal_revisions SET snapshot = snapshot || '{"status": "available"}' WHERE NOT snapshot ? 'status';
+
+ALTER TABLE animal_revisions DROP CONSTRAINT IF EXISTS animal_revisions_operation_check;
+ALTER TABLE animal_revisions ADD CONSTRAINT animal_revisions_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'restore', 'purge', 'transition'));
+
+-- like animal_revisions, status changes outlive purged animals
+CREATE TABLE IF NOT EXISTS animal_status_changes (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    animal_id BIGINT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS animal_status_changes_animal_id_idx ON animal_status_changes (animal_id, id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS enclosures (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- An enclosure without rows here takes any species. Otherwise an animal must be
+-- of one of the taxa, or of a taxon below one of them.
+CREATE TABLE IF NOT EXISTS enclosure_species (
+    enclosure_id BIGINT NOT NULL REFERENCES enclosures (id) ON DELETE CASCADE,
+    species_id BIGINT NOT NULL REFERENCES species (id),
+    PRIMARY KEY (enclosure_id, species_id)
+);
+
+CREATE INDEX IF NOT EXISTS enclosure_species_species_id_idx ON enclosure_species (species_id);
+
+-- capacity is enforced by the application, which locks the enclosure row
+-- before counting its animals, see MoveAnimal
+ALTER TABLE animals
+    ADD COLUMN IF NOT EXISTS enclosure_id BIGINT CONSTRAINT animals_enclosure_id_fkey REFERENCES enclosures (id);
+
+CREATE INDEX IF NOT EXISTS animals_enclosure_id_idx ON animals (enclosure_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE animal_revisions DROP CONSTRAINT IF EXISTS animal_revisions_operation_check;
+ALTER TABLE animal_revisions ADD CONSTRAINT animal_revisions_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'restore', 'purge', 'transition', 'move'));
@@ -0,0 +1,23 @@
+ALTER TABLE animals
+    ADD COLUMN IF NOT EXISTS birth_date DATE,
+    ADD COLUMN IF NOT EXISTS birth_date_estimated BOOLEAN NOT NULL DEFAULT false;
+
+-- an age was true when it was last written, so the animal was born that many
+-- years before then; the day of the year is a guess
+UPDATE animals
+SET birth_date = (updated_at::date - make_interval(years => COALESCE(age, 0)))::date,
+    birth_date_estimated = true
+WHERE birth_date IS NULL;
+
+ALTER TABLE animals ALTER COLUMN birth_date SET NOT NULL;
+ALTER TABLE animals DROP COLUMN IF EXISTS age;
+
+CREATE INDEX IF NOT EXISTS animals_birth_date_idx ON animals (birth_date, id);
+
+-- snapshots are rebuilt into animals rows by as_of queries, so they need a
+-- birth date as well; their age stays as it was at the time of the revision
+UPDATE animal_revisions
+SET snapshot = snapshot || jsonb_build_object(
+    'birth_date', (changed_at::date - make_interval(years => COALESCE((snapshot ->> 'age')::int, 0)))::date,
+    'birth_date_estimated', true)
+WHERE NOT snapshot ? 'birth_date';
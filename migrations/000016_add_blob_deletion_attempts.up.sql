@@ -0,0 +1,6 @@
+-- A blob that fails to delete is retried with a growing delay, so that keys
+-- stuck at the front of the queue do not hold up newer deletions.
+ALTER TABLE attachment_blob_deletions ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE attachment_blob_deletions ADD COLUMN IF NOT EXISTS last_attempt_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS attachment_blob_deletions_attempts_idx ON attachment_blob_deletions (attempts, queued_at);